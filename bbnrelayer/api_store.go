@@ -0,0 +1,133 @@
+package bbnrelayer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiStoreTimeout is the timeout of a request to the status API of a running relayer
+const apiStoreTimeout = 30 * time.Second
+
+// errReadOnlyStore is returned upon writes to a store read from the status API
+var errReadOnlyStore = errors.New("the store is read from the status API of a running relayer and is read-only")
+
+// apiStore reads the stores of a running relayer from its status API, so
+// that they can be read while the relayer holds the DB
+type apiStore struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newAPIStore(addr string) apiStore {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return apiStore{
+		baseURL: strings.TrimSuffix(addr, "/"),
+		client:  &http.Client{Timeout: apiStoreTimeout},
+	}
+}
+
+// get decodes the JSON response to a GET request of the given path into obj
+func (s apiStore) get(path string, query url.Values, obj interface{}) error {
+	u := s.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := s.client.Get(u)
+	if err != nil {
+		return fmt.Errorf("error requesting the status API (%s): %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || len(apiErr.Error) == 0 {
+			return fmt.Errorf("error requesting the status API (%s): %s", u, resp.Status)
+		}
+		return fmt.Errorf("error requesting the status API (%s): %s", u, apiErr.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(obj); err != nil {
+		return fmt.Errorf("error decoding the response of the status API (%s): %w", u, err)
+	}
+	return nil
+}
+
+// apiClientStore is a read-only ClientStore read from the status API of a running relayer
+type apiClientStore struct {
+	apiStore
+}
+
+// NewAPIClientStore returns a read-only ClientStore that reads the client
+// records of the relayer whose status API listens on the given address
+func NewAPIClientStore(addr string) ClientStore {
+	return &apiClientStore{apiStore: newAPIStore(addr)}
+}
+
+func (s *apiClientStore) Get(babylonChainID string, czChainID string) (*ClientRecord, error) {
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := records[ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return record, nil
+}
+
+func (s *apiClientStore) Set(string, string, *ClientRecord) error {
+	return errReadOnlyStore
+}
+
+func (s *apiClientStore) Update(string, string, func(record *ClientRecord) error) error {
+	return errReadOnlyStore
+}
+
+func (s *apiClientStore) Delete(string, string) error {
+	return errReadOnlyStore
+}
+
+func (s *apiClientStore) List() (map[ClientKey]*ClientRecord, error) {
+	records := map[ClientKey]*ClientRecord{}
+	if err := s.get("/records", nil, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *apiClientStore) Close() error {
+	return nil
+}
+
+// apiCostStore is a read-only CostStore read from the status API of a running relayer
+type apiCostStore struct {
+	apiStore
+}
+
+// NewAPICostStore returns a read-only CostStore that reads the cost records
+// of the relayer whose status API listens on the given address
+func NewAPICostStore(addr string) CostStore {
+	return &apiCostStore{apiStore: newAPIStore(addr)}
+}
+
+func (s *apiCostStore) Add(*CostRecord) error {
+	return errReadOnlyStore
+}
+
+func (s *apiCostStore) List(since time.Time) ([]*CostRecord, error) {
+	var records []*CostRecord
+	query := url.Values{"since": []string{since.UTC().Format(time.RFC3339Nano)}}
+	if err := s.get("/costs", query, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *apiCostStore) Close() error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package bbnrelayer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	"go.uber.org/zap"
+)
+
+// newTestStatusAPI serves the status API of a relayer with the given stores
+func newTestStatusAPI(t *testing.T, clientStore ClientStore, costStore CostStore) *httptest.Server {
+	r := New("", nil, zap.NewNop(), relaydebug.NewPrometheusMetrics(), clientStore, costStore, 0)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/records", r.ServeRecords)
+	mux.HandleFunc("/costs", r.ServeCosts)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAPIClientStore(t *testing.T) {
+	clientStore := NewMemClientStore()
+	record := &ClientRecord{ClientID: "07-tendermint-0", BabylonChainID: "bbn-test-3", Status: "Active"}
+	if err := clientStore.Set("bbn-test-3", "osmo-test-5", record); err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestStatusAPI(t, clientStore, NewMemCostStore())
+
+	// the address of the debug server is given without a scheme
+	store := NewAPIClientStore(srv.Listener.Addr().String())
+	got, err := store.Get("bbn-test-3", "osmo-test-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ClientID != record.ClientID || got.Status != record.Status {
+		t.Errorf("got record %+v, expected %+v", got, record)
+	}
+	if _, err := store.Get("bbn-test-3", "juno-1"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("got error %v for a missing record, expected %v", err, ErrClientNotFound)
+	}
+	if err := store.Set("bbn-test-3", "juno-1", record); !errors.Is(err, errReadOnlyStore) {
+		t.Errorf("got error %v upon a write, expected %v", err, errReadOnlyStore)
+	}
+}
+
+func TestAPICostStore(t *testing.T) {
+	costStore := NewMemCostStore()
+	now := time.Now().UTC()
+	for i, d := range []time.Duration{2 * time.Hour, time.Hour, 0} {
+		record := &CostRecord{BabylonChainID: "bbn-test-3", CZChainID: "osmo-test-5", Time: now.Add(-d), Height: int64(i + 1)}
+		if err := costStore.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := newTestStatusAPI(t, NewMemClientStore(), costStore)
+
+	store := NewAPICostStore(srv.URL)
+	records, err := store.List(now.Add(-90 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Height != 2 || records[1].Height != 3 {
+		t.Errorf("got %d records since 90m ago, expected the last 2 in chronological order", len(records))
+	}
+	records, err = store.List(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("got %d records since an hour later, expected none", len(records))
+	}
+}
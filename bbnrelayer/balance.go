@@ -0,0 +1,350 @@
+package bbnrelayer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/babylonchain/babylon-relayer/config"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+// balanceLevel is the alert level of the balance of an account
+type balanceLevel int
+
+const (
+	balanceLevelOK balanceLevel = iota
+	balanceLevelWarn
+	balanceLevelCritical
+)
+
+// feeSmoothing is the weight of the latest observation in the moving average
+// of the fee per client update
+const feeSmoothing = 0.2
+
+// feePayer is an account that pays the fees of client updates
+type feePayer struct {
+	// name is the name of the key, or the address of the fee granter
+	name    string
+	address string
+	// level is only accessed by the watching go routine
+	level balanceLevel
+
+	// the following are guarded by the mutex of the watcher
+	// feePerUpdate is the moving average of the fee per client update
+	feePerUpdate float64
+	// spent is the total fees spent since the watcher started
+	spent float64
+}
+
+// FeePayer is an account that pays the fees of client updates, i.e., either
+// a key, whose address is derived from the keyring, or the fee granter
+type FeePayer struct {
+	// Name is the name of the key, or the address of the fee granter
+	Name string
+	// Address is the address of the fee granter, and empty for a key
+	Address string
+}
+
+// FeePayers returns the accounts that pay the fees of the client updates of
+// the given CZ on the given Babylon chain, i.e., the fee granter if any, or
+// else the key the CZ is pinned to, or else every key of the key pool
+func FeePayers(
+	babylonChain *relayer.Chain,
+	babylonChainCfg *config.BabylonChainConfig,
+	chains relayer.Chains,
+	czChainID string,
+) []FeePayer {
+	if granter := babylonChainCfg.Grants.FeeGranter; len(granter) > 0 {
+		return []FeePayer{{Name: granter, Address: granter}}
+	}
+	pool := newKeyPool(babylonChain, babylonChainCfg, chains)
+	if key, ok := pool.pinned[czChainID]; ok {
+		return []FeePayer{{Name: key}}
+	}
+	payers := make([]FeePayer, 0, len(pool.keys))
+	for _, key := range pool.keys {
+		payers = append(payers, FeePayer{Name: key})
+	}
+	return payers
+}
+
+// balanceWatcher periodically queries the balances of the accounts that pay
+// the fees of client updates on a Babylon chain, estimates how long they
+// last from the fees observed so far, and pauses the client updates of
+// non-critical CZs while any balance is below the critical threshold
+type balanceWatcher struct {
+	r     *Relayer
+	chain *relayer.Chain
+	cfg   config.BalanceConfig
+	denom string
+	// payers are keyed by name, and only written upon creation of the watcher
+	payers map[string]*feePayer
+	// nonCritical are the chain IDs of CZs that are paused to save fees
+	nonCritical map[string]bool
+	startTime   time.Time
+
+	mu     sync.Mutex
+	paused atomic.Bool
+}
+
+// newBalanceWatcher creates the balance watcher of the given Babylon chain,
+// whose keys and grants have to be set up with UseSigners
+func newBalanceWatcher(r *Relayer, babylonChain *relayer.Chain, babylonChainCfg *config.BabylonChainConfig) (*balanceWatcher, error) {
+	cc, err := cosmosProvider(babylonChain)
+	if err != nil {
+		return nil, err
+	}
+
+	denom := babylonChainCfg.Balance.Denom
+	if len(denom) == 0 {
+		gasPrices, err := sdk.ParseDecCoins(cc.PCfg.GasPrices)
+		if err != nil || len(gasPrices) == 0 {
+			return nil, fmt.Errorf("failed to get fee denom from gas prices %q of chain %s", cc.PCfg.GasPrices, babylonChain.ChainID())
+		}
+		denom = gasPrices[0].Denom
+	}
+
+	w := &balanceWatcher{
+		r:           r,
+		chain:       babylonChain,
+		cfg:         babylonChainCfg.Balance,
+		denom:       denom,
+		payers:      map[string]*feePayer{},
+		nonCritical: map[string]bool{},
+		startTime:   time.Now(),
+	}
+
+	// the fee granter pays the fees of all keys
+	if granter := babylonChainCfg.Grants.FeeGranter; len(granter) > 0 {
+		w.payers[granter] = &feePayer{name: granter, address: granter}
+	} else {
+		for _, key := range r.signingKeys(babylonChain) {
+			addr, err := cc.GetKeyAddressForKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get address of key %s: %w", key, err)
+			}
+			address, err := cc.EncodeBech32AccAddr(addr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode address of key %s: %w", key, err)
+			}
+			w.payers[key] = &feePayer{name: key, address: address}
+		}
+	}
+
+	for _, czCfg := range babylonChainCfg.CZs {
+		if czChain, ok := r.cfg.Chains[czCfg.ChainName]; ok && !czCfg.Critical {
+			w.nonCritical[czChain.ChainID()] = true
+		}
+	}
+	return w, nil
+}
+
+// run keeps checking the balances every interval until ctx is done
+func (w *balanceWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check queries the balance of each payer, reports it, and pauses or resumes
+// the non-critical CZs according to the alert levels of the balances
+func (w *balanceWatcher) check(ctx context.Context) {
+	cc, err := cosmosProvider(w.chain)
+	if err != nil {
+		return
+	}
+
+	critical := false
+	for _, p := range w.payers {
+		balance, err := cc.QueryBalanceWithAddress(ctx, p.address)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.r.logger.Warn(
+					"Failed to query balance of account",
+					zap.String("src_chain_id", w.chain.ChainID()),
+					zap.String("account", p.name),
+					zap.Error(err),
+				)
+			}
+			// the account keeps its last known level
+		} else {
+			w.report(p, balance.AmountOf(w.denom))
+		}
+		if p.level == balanceLevelCritical {
+			critical = true
+		}
+	}
+
+	w.setPaused(w.cfg.PauseNonCritical && critical)
+}
+
+// report exports the given balance of the given payer and its estimated
+// runway, and logs once the balance crosses a threshold
+func (w *balanceWatcher) report(p *feePayer, balance sdkmath.Int) {
+	chainID := w.chain.ChainID()
+	amount, _ := new(big.Float).SetInt(balance.BigInt()).Float64()
+	w.r.metrics.AccountBalanceGauge.WithLabelValues(chainID, p.name, w.denom).Set(amount)
+
+	w.mu.Lock()
+	feePerUpdate, spent := p.feePerUpdate, p.spent
+	w.mu.Unlock()
+
+	var runway time.Duration
+	if feePerUpdate > 0 {
+		w.r.metrics.AccountRunwayGauge.WithLabelValues(chainID, p.name, "updates").Set(amount / feePerUpdate)
+	}
+	if spent > 0 {
+		spentPerSecond := spent / time.Since(w.startTime).Seconds()
+		w.r.metrics.AccountRunwayGauge.WithLabelValues(chainID, p.name, "seconds").Set(amount / spentPerSecond)
+		runway = time.Duration(amount / spentPerSecond * float64(time.Second))
+	}
+
+	level := balanceLevelOK
+	switch {
+	case w.cfg.CriticalThreshold > 0 && balance.LT(sdkmath.NewIntFromUint64(w.cfg.CriticalThreshold)):
+		level = balanceLevelCritical
+	case w.cfg.WarnThreshold > 0 && balance.LT(sdkmath.NewIntFromUint64(w.cfg.WarnThreshold)):
+		level = balanceLevelWarn
+	}
+	w.r.metrics.BalanceAlertGauge.WithLabelValues(chainID, p.name).Set(float64(level))
+	if level == p.level {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("src_chain_id", chainID),
+		zap.String("account", p.name),
+		zap.String("address", p.address),
+		zap.String("balance", balance.String()+w.denom),
+		zap.Duration("estimated_runway", runway),
+	}
+	switch level {
+	case balanceLevelCritical:
+		w.r.logger.Error("Balance of account is below the critical threshold", append(fields, zap.Uint64("threshold", w.cfg.CriticalThreshold))...)
+	case balanceLevelWarn:
+		w.r.logger.Warn("Balance of account is below the warning threshold", append(fields, zap.Uint64("threshold", w.cfg.WarnThreshold))...)
+	default:
+		w.r.logger.Info("Balance of account is back above the thresholds", fields...)
+	}
+	p.level = level
+}
+
+// setPaused pauses or resumes the client updates of non-critical CZs
+func (w *balanceWatcher) setPaused(paused bool) {
+	if w.paused.Swap(paused) == paused {
+		return
+	}
+
+	dstChainIDs := make([]string, 0, len(w.nonCritical))
+	for dstChainID := range w.nonCritical {
+		dstChainIDs = append(dstChainIDs, dstChainID)
+		value := 0.0
+		if paused {
+			value = 1
+		}
+		w.r.metrics.PausedChainsGauge.WithLabelValues(w.chain.ChainID(), dstChainID).Set(value)
+	}
+	if paused {
+		w.r.logger.Warn(
+			"Pausing client updates of non-critical CZs to save fees",
+			zap.String("src_chain_id", w.chain.ChainID()),
+			zap.Strings("dst_chain_ids", dstChainIDs),
+		)
+	} else {
+		w.r.logger.Info(
+			"Resuming client updates of non-critical CZs",
+			zap.String("src_chain_id", w.chain.ChainID()),
+			zap.Strings("dst_chain_ids", dstChainIDs),
+		)
+	}
+}
+
+// observeFees records the given fees paid by the given payer for a tx with
+// the given number of client updates
+func (w *balanceWatcher) observeFees(payer string, fees sdk.Coins, numUpdates int) {
+	p, ok := w.payers[payer]
+	if !ok || numUpdates == 0 {
+		return
+	}
+	amount, _ := new(big.Float).SetInt(fees.AmountOf(w.denom).BigInt()).Float64()
+	perUpdate := amount / float64(numUpdates)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p.spent += amount
+	if p.feePerUpdate == 0 {
+		p.feePerUpdate = perUpdate
+	} else {
+		p.feePerUpdate = feeSmoothing*perUpdate + (1-feeSmoothing)*p.feePerUpdate
+	}
+}
+
+// WatchBalance starts watching the balances of the accounts that pay the fees
+// of client updates on the given Babylon chain in a go routine added to wg.
+// It should be called after UseSigners and before relaying starts.
+func (r *Relayer) WatchBalance(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	babylonChain *relayer.Chain,
+	babylonChainCfg *config.BabylonChainConfig,
+) error {
+	if babylonChainCfg.Balance.Interval <= 0 {
+		return nil
+	}
+	w, err := newBalanceWatcher(r, babylonChain, babylonChainCfg)
+	if err != nil {
+		return err
+	}
+	r.balanceWatchers[babylonChain.ChainID()] = w
+	r.logger.Info(
+		"Watching balances of fee payers",
+		zap.String("src_chain_id", babylonChain.ChainID()),
+		zap.String("denom", w.denom),
+		zap.Duration("interval", w.cfg.Interval),
+		zap.Uint64("warn_threshold", w.cfg.WarnThreshold),
+		zap.Uint64("critical_threshold", w.cfg.CriticalThreshold),
+		zap.Bool("pause_non_critical", w.cfg.PauseNonCritical),
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.run(ctx)
+	}()
+	return nil
+}
+
+// observeFees records the given fees of a tx with the given number of client
+// updates signed by the given key on the given chain
+func (r *Relayer) observeFees(chainID string, key string, fees sdk.Coins, numUpdates int) {
+	w, ok := r.balanceWatchers[chainID]
+	if !ok {
+		return
+	}
+	payer := key
+	if granter := r.grants[chainID].FeeGranter; len(granter) > 0 {
+		payer = granter
+	}
+	w.observeFees(payer, fees, numUpdates)
+}
+
+// pausedToSaveFees returns whether the client updates of dst chain on src
+// chain are paused since the balance on src chain is critical
+func (r *Relayer) pausedToSaveFees(srcChainID string, dstChainID string) bool {
+	w, ok := r.balanceWatchers[srcChainID]
+	return ok && w.paused.Load() && w.nonCritical[dstChainID]
+}
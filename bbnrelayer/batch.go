@@ -0,0 +1,221 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/provider"
+	"go.uber.org/zap"
+)
+
+// batchRequest is a MsgUpdateClient of a CZ waiting to be sent in a batch
+type batchRequest struct {
+	dstChainID string
+	msg        provider.RelayerMessage
+	// policy is the relay policy of the CZ
+	policy config.RelayPolicy
+	// result is buffered so that the batch sender never blocks on it
+	result chan batchResult
+}
+
+// batchResult is the result of sending the msg of a batchRequest
+type batchResult struct {
+	txResp *provider.RelayerTxResponse
+	err    error
+}
+
+// batchSender collects MsgUpdateClients of all CZs relayed to a Babylon chain
+// within a short window, and sends them to the Babylon chain in a single tx
+type batchSender struct {
+	r        *Relayer
+	src      *relayer.Chain
+	cfg      config.BatchConfig
+	requests chan *batchRequest
+}
+
+func newBatchSender(r *Relayer, src *relayer.Chain, cfg config.BatchConfig) *batchSender {
+	return &batchSender{
+		r:        r,
+		src:      src,
+		cfg:      cfg,
+		requests: make(chan *batchRequest),
+	}
+}
+
+// submit adds the given msg of dst chain to the next batch, and returns the
+// response of the tx that includes the msg
+func (b *batchSender) submit(
+	ctx context.Context,
+	dstChainID string,
+	msg provider.RelayerMessage,
+	policy config.RelayPolicy,
+) (*provider.RelayerTxResponse, error) {
+	req := &batchRequest{
+		dstChainID: dstChainID,
+		msg:        msg,
+		policy:     policy,
+		result:     make(chan batchResult, 1),
+	}
+	select {
+	case b.requests <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case res := <-req.result:
+		return res.txResp, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run keeps collecting and sending batches until ctx is done. Every request
+// received by run gets a result.
+func (b *batchSender) run(ctx context.Context) {
+	for {
+		// wait for the first request of the batch
+		var batch []*batchRequest
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-b.requests:
+			batch = append(batch, req)
+		}
+
+		// collect other requests within the window
+		timer := time.NewTimer(b.cfg.Window)
+	collect:
+		for b.cfg.MaxMsgs <= 0 || len(batch) < b.cfg.MaxMsgs {
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			case <-ctx.Done():
+				break collect
+			}
+		}
+		timer.Stop()
+
+		// the batch is allowed to finish within the grace period upon shutdown
+		// NOTE: requests pinned to different keys are sent in separate txs
+		sendCtx, cancelSend := b.r.sendContext(ctx)
+		for _, keyBatch := range b.groupByKey(batch) {
+			b.sendOrSplit(sendCtx, keyBatch.key, keyBatch.requests)
+		}
+		cancelSend()
+	}
+}
+
+// keyBatch is the part of a batch signed by a key
+type keyBatch struct {
+	key      string
+	requests []*batchRequest
+}
+
+// groupByKey groups the requests in the given batch by the keys that sign
+// them in the order of the batch, where a single key is picked for all
+// requests of CZs that are not pinned to a key
+func (b *batchSender) groupByKey(batch []*batchRequest) []*keyBatch {
+	var (
+		groups      []*keyBatch
+		unpinnedKey string
+	)
+	indices := map[string]int{}
+	for _, req := range batch {
+		key, pinned := b.r.pinnedKey(b.src, req.dstChainID)
+		if !pinned {
+			if len(unpinnedKey) == 0 {
+				unpinnedKey = b.r.pickKey(b.src, req.dstChainID)
+			}
+			key = unpinnedKey
+		}
+		i, ok := indices[key]
+		if !ok {
+			i = len(groups)
+			indices[key] = i
+			groups = append(groups, &keyBatch{key: key})
+		}
+		groups[i].requests = append(groups[i].requests, req)
+	}
+	return groups
+}
+
+// sendOrSplit sends the msgs of the given requests in a single tx. If the msgs
+// need more gas than a tx can use, which is found by simulating the tx before
+// it is broadcast, the requests are split into halves that are sent
+// separately, until they fit in txs. Likewise, if the tx fails, e.g., due to
+// an invalid msg of a CZ, the requests are split until the failure is
+// attributed to the requests of individual CZs.
+func (b *batchSender) sendOrSplit(ctx context.Context, key string, batch []*batchRequest) {
+	msgs := make([]provider.RelayerMessage, 0, len(batch))
+	dstChainIDs := make([]string, 0, len(batch))
+	for _, req := range batch {
+		msgs = append(msgs, req.msg)
+		dstChainIDs = append(dstChainIDs, req.dstChainID)
+	}
+
+	txResp, err := b.r.sendMsgs(ctx, b.src, key, dstChainIDs, msgs, batchPolicy(batch))
+	if err == nil || len(batch) == 1 || ctx.Err() != nil || !splittable(err) {
+		if err == nil {
+			b.r.logger.Debug(
+				"sent batch of client updates",
+				zap.String("src_chain_id", b.src.ChainID()),
+				zap.Strings("dst_chain_ids", dstChainIDs),
+				zap.String("key", key),
+				zap.String("tx_hash", txResp.TxHash),
+			)
+		}
+		for _, req := range batch {
+			req.result <- batchResult{txResp: txResp, err: err}
+		}
+		return
+	}
+
+	var gasErr *gasLimitError
+	if errors.As(err, &gasErr) {
+		b.r.logger.Debug(
+			"batch of client updates exceeds the gas limit, split the batch",
+			zap.String("src_chain_id", b.src.ChainID()),
+			zap.Strings("dst_chain_ids", dstChainIDs),
+			zap.Uint64("gas", gasErr.gas),
+			zap.Uint64("gas_limit", gasErr.limit),
+		)
+	} else {
+		b.r.logger.Info(
+			"Failed to send batch of client updates, split the batch",
+			zap.String("src_chain_id", b.src.ChainID()),
+			zap.Strings("dst_chain_ids", dstChainIDs),
+			zap.Error(err),
+		)
+	}
+	mid := len(batch) / 2
+	b.sendOrSplit(ctx, key, batch[:mid])
+	b.sendOrSplit(ctx, key, batch[mid:])
+}
+
+// batchPolicy returns the relay policy with which the given requests are sent
+// together, i.e., the most patient of the policies of their CZs, so that no
+// CZ gives up on the tx earlier than it would on its own
+func batchPolicy(batch []*batchRequest) config.RelayPolicy {
+	policy := batch[0].policy
+	for _, req := range batch[1:] {
+		if req.policy.Retries > policy.Retries {
+			policy.Retries = req.policy.Retries
+		}
+		if req.policy.RetryDelay > policy.RetryDelay {
+			policy.RetryDelay = req.policy.RetryDelay
+		}
+	}
+	return policy
+}
+
+// splittable returns whether sending a batch in smaller txs might avoid the
+// given error. Errors of the signing account apply to every tx.
+func splittable(err error) bool {
+	errClass := ClassifyError(err)
+	return errClass != ErrClassInsufficientFunds && errClass != ErrClassSequenceMismatch
+}
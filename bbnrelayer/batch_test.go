@@ -0,0 +1,141 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/provider"
+	"go.uber.org/zap"
+)
+
+// czMsg is a msg of the CZ with the given chain ID
+type czMsg string
+
+func (czMsg) Type() string              { return "/ibc.core.client.v1.MsgUpdateClient" }
+func (czMsg) MsgBytes() ([]byte, error) { return nil, nil }
+
+func newTestBatch(dstChainIDs ...string) []*batchRequest {
+	batch := make([]*batchRequest, 0, len(dstChainIDs))
+	for _, dstChainID := range dstChainIDs {
+		batch = append(batch, &batchRequest{
+			dstChainID: dstChainID,
+			msg:        czMsg(dstChainID),
+			result:     make(chan batchResult, 1),
+		})
+	}
+	return batch
+}
+
+func TestSendOrSplit(t *testing.T) {
+	src := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testBabylonChainID}, false)
+	tests := []struct {
+		name        string
+		dstChainIDs []string
+		badErr      error
+		// maxMsgs is the number of msgs that fit in a tx, where 0 is unlimited
+		maxMsgs     int
+		wantTxSizes []int
+		wantTxs     map[string]int
+	}{
+		{
+			// the batch is split until the invalid msg is sent alone
+			name:        "invalid msg",
+			dstChainIDs: []string{"cz-0", "cz-1", "bad", "cz-2"},
+			badErr:      ibctm.ErrInvalidHeader,
+			wantTxSizes: []int{4, 2, 2, 1, 1},
+			wantTxs:     map[string]int{"cz-0": 1, "cz-1": 1, "cz-2": 2},
+		},
+		{
+			// every tx of the key would fail, so the batch is not split
+			name:        "insufficient funds",
+			dstChainIDs: []string{"cz-0", "cz-1", "bad", "cz-2"},
+			badErr:      sdkerrors.ErrInsufficientFunds,
+			wantTxSizes: []int{4},
+		},
+		{
+			// the batch is split before any tx over the gas limit is broadcast
+			name:        "gas limit",
+			dstChainIDs: []string{"cz-0", "cz-1", "cz-2", "cz-3", "cz-4"},
+			maxMsgs:     2,
+			wantTxSizes: []int{5, 2, 3, 1, 2},
+			wantTxs:     map[string]int{"cz-0": 1, "cz-1": 1, "cz-2": 2, "cz-3": 3, "cz-4": 3},
+		},
+	}
+	for _, tt := range tests {
+		costStore := NewMemCostStore()
+		r := New("", nil, zap.NewNop(), relaydebug.NewPrometheusMetrics(), NewMemClientStore(), costStore, 0)
+		var (
+			txSizes []int
+			txs     int
+		)
+		r.broadcastTx = func(_ context.Context, _ *relayer.Chain, _ string, msgs []provider.RelayerMessage, _ config.RelayPolicy) (*sdk.TxResponse, sdk.Coins, error) {
+			txSizes = append(txSizes, len(msgs))
+			if tt.maxMsgs > 0 && len(msgs) > tt.maxMsgs {
+				return nil, nil, &gasLimitError{gas: uint64(len(msgs)) * 100000, limit: uint64(tt.maxMsgs) * 100000}
+			}
+			for _, msg := range msgs {
+				if msg == czMsg("bad") {
+					return nil, nil, tt.badErr
+				}
+			}
+			txs++
+			return &sdk.TxResponse{TxHash: fmt.Sprint(txs), Height: 100}, nil, nil
+		}
+
+		batch := newTestBatch(tt.dstChainIDs...)
+		newBatchSender(r, src, config.BatchConfig{}).sendOrSplit(context.Background(), "relayer", batch)
+
+		if fmt.Sprint(txSizes) != fmt.Sprint(tt.wantTxSizes) {
+			t.Errorf("%s: got txs of sizes %v, expected %v", tt.name, txSizes, tt.wantTxSizes)
+		}
+		for _, req := range batch {
+			res := <-req.result
+			if req.dstChainID == "bad" || tt.wantTxs == nil {
+				if !errors.Is(res.err, tt.badErr) {
+					t.Errorf("%s: got error %v for %s, expected %v", tt.name, res.err, req.dstChainID, tt.badErr)
+				}
+				continue
+			}
+			if res.err != nil {
+				t.Errorf("%s: got error %v for %s, expected none", tt.name, res.err, req.dstChainID)
+				continue
+			}
+			if want := fmt.Sprint(tt.wantTxs[req.dstChainID]); res.txResp.TxHash != want {
+				t.Errorf("%s: got tx %s for %s, expected tx %s", tt.name, res.txResp.TxHash, req.dstChainID, want)
+			}
+		}
+
+		// only the costs of included txs are recorded
+		costs, err := costStore.List(time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(costs) != len(tt.wantTxs) {
+			t.Errorf("%s: got %d cost records, expected %d", tt.name, len(costs), len(tt.wantTxs))
+		}
+	}
+}
+
+func TestBatchPolicy(t *testing.T) {
+	batch := newTestBatch("cz-0", "cz-1", "cz-2")
+	batch[0].policy = config.RelayPolicy{Interval: time.Minute, Retries: 1, RetryDelay: 3 * time.Second}
+	batch[1].policy = config.RelayPolicy{Interval: time.Hour, Retries: 5, RetryDelay: time.Second}
+	batch[2].policy = config.RelayPolicy{Interval: time.Hour, Retries: 3, RetryDelay: 2 * time.Second}
+
+	got := batchPolicy(batch)
+	if got.Retries != 5 || got.RetryDelay != 3*time.Second {
+		t.Errorf("got %d retries with delay %v, expected 5 retries with delay 3s", got.Retries, got.RetryDelay)
+	}
+	if got.Interval != time.Minute {
+		t.Errorf("got interval %v, expected the interval of the first request", got.Interval)
+	}
+}
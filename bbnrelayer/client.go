@@ -2,12 +2,15 @@ package bbnrelayer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/avast/retry-go/v4"
+	"github.com/babylonchain/babylon-relayer/config"
 	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	relayercmd "github.com/cosmos/relayer/v2/cmd"
 	"github.com/cosmos/relayer/v2/relayer"
 	"github.com/cosmos/relayer/v2/relayer/provider"
@@ -15,33 +18,130 @@ import (
 )
 
 // Relayer is a relayer that allows to relay multiple chains concurrently.
-// It is made thread-safe to avoid account sequence mismatch errors in Cosmos SDK accounts.
+// It is made thread-safe to avoid account sequence mismatch errors in Cosmos SDK accounts,
+// while allowing txs of multiple chains to be in flight at once.
 type Relayer struct {
 	homePath string
 	cfg      *relayercmd.Config
 	logger   *zap.Logger
 	metrics  *relaydebug.PrometheusMetrics
+
+	// shutdownGracePeriod is the period in which in-flight txs are allowed
+	// to finish after the context is cancelled
+	shutdownGracePeriod time.Duration
+
+	// clientStore and costStore are opened once and shared by all relaying go routines
+	clientStore ClientStore
+	costStore   CostStore
+	supervisor  *supervisor
+
+	// batchSenders are the batch senders of Babylon chains with batching
+	// enabled, keyed by chain ID. It is only written before relaying starts.
+	batchSenders map[string]*batchSender
+	// keyPools are the key pools of Babylon chains, keyed by chain ID. It is
+	// only written before relaying starts.
+	keyPools map[string]*keyPool
+	// grants are the grants to the keys of Babylon chains, keyed by chain ID.
+	// It is only written before relaying starts.
+	grants map[string]config.GrantsConfig
+	// balanceWatchers are the balance watchers of Babylon chains, keyed by
+	// chain ID. It is only written before relaying starts.
+	balanceWatchers map[string]*balanceWatcher
+
+	// haltedClients are the errors with which the clients found misbehaving
+	// are halted, keyed by src and dst chain IDs
+	haltedClients   map[chainPair]error
+	haltedClientsMu sync.RWMutex
+
+	// sequenceManagers assign account sequences to the txs signed by each
+	// key on each Babylon chain, keyed by chain ID and key name
+	sequenceManagers   map[string]*sequenceManager
+	sequenceManagersMu sync.Mutex
+
+	// broadcastTx sends a tx to a Babylon chain and waits for its inclusion,
+	// which is broadcastAndWait unless replaced in tests
+	broadcastTx func(
+		ctx context.Context,
+		src *relayer.Chain,
+		key string,
+		msgs []provider.RelayerMessage,
+		policy config.RelayPolicy,
+	) (*sdk.TxResponse, sdk.Coins, error)
 }
 
-func New(homePath string, cfg *relayercmd.Config, logger *zap.Logger, metrics *relaydebug.PrometheusMetrics) *Relayer {
-	return &Relayer{
+func New(
+	homePath string,
+	cfg *relayercmd.Config,
+	logger *zap.Logger,
+	metrics *relaydebug.PrometheusMetrics,
+	clientStore ClientStore,
+	costStore CostStore,
+	shutdownGracePeriod time.Duration,
+) *Relayer {
+	r := &Relayer{
 		homePath: homePath,
 		cfg:      cfg,
 		logger:   logger,
 		metrics:  metrics,
+
+		shutdownGracePeriod: shutdownGracePeriod,
+
+		clientStore: clientStore,
+		costStore:   costStore,
+		supervisor:  newSupervisor(DefaultSupervisorConfig(), logger, metrics),
+
+		batchSenders: map[string]*batchSender{},
+		keyPools:     map[string]*keyPool{},
+		grants:       map[string]config.GrantsConfig{},
+
+		balanceWatchers: map[string]*balanceWatcher{},
+		haltedClients:   map[chainPair]error{},
+
+		sequenceManagers: map[string]*sequenceManager{},
 	}
+	r.broadcastTx = r.broadcastAndWait
+	return r
+}
+
+// Close closes the client store and the cost store of the relayer. It should
+// be called after all relaying go routines have finished.
+func (r *Relayer) Close() error {
+	return errors.Join(r.clientStore.Close(), r.costStore.Close())
 }
 
-// UpdateClient updates the IBC light client on src chain that tracks dst chain
+// ChainStatuses returns the status of the relaying loop of every chain
+// started by KeepUpdatingClients, or relayed by KeepUpdatingClient
+func (r *Relayer) ChainStatuses() []ChainStatus {
+	statuses := r.supervisor.chainStatuses()
+	now := time.Now()
+	for i := range statuses {
+		client := &statuses[i].Client
+		if err := r.haltedClientError(statuses[i].SrcChainID, statuses[i].DstChainID); err != nil {
+			client.Halted = err.Error()
+		}
+		if !client.LatestConsensusTime.IsZero() {
+			client.TrustRemaining = client.LatestConsensusTime.Add(client.TrustingPeriod).Sub(now).Truncate(time.Second)
+		}
+	}
+	return statuses
+}
+
+// UpdateClient updates the IBC light client on src chain that tracks dst chain.
+// Errors are returned as UpdateClientError so that callers can decide how to react.
 // (adapted from https://github.com/cosmos/relayer/blob/v2.1.2/relayer/client.go#L17)
 func (r *Relayer) UpdateClient(
 	ctx context.Context,
 	src *relayer.Chain,
 	dst *relayer.Chain,
-	numRetries uint,
+	policy config.RelayPolicy,
 ) error {
+	// a client found misbehaving must not be updated with headers that may come from a fork
+	if err := r.haltedClientError(src.ChainID(), dst.ChainID()); err != nil {
+		return err
+	}
+
 	// get client ID for the dst IBC light client on src chain in DB
-	clientID, err := r.getClientID(dst.ChainID())
+	record, err := r.clientStore.Get(src.ChainID(), dst.ChainID())
 	if err != nil {
 		r.logger.Error(
 			"failed to get client ID for CZ light client",
@@ -51,6 +151,16 @@ func (r *Relayer) UpdateClient(
 		)
 		return err
 	}
+	// switch back to the subject client once it is recovered from the substitute client
+	if record, err = r.checkRecovery(ctx, src, dst, record); err != nil {
+		return err
+	}
+	clientID := record.ClientID
+
+	// an expired or frozen client cannot be updated anymore
+	if err := r.checkClientStatus(ctx, src, dst, clientID); err != nil {
+		return err
+	}
 
 	// query the latest heights on src and dst
 	var srch, dsth int64
@@ -61,47 +171,29 @@ func (r *Relayer) UpdateClient(
 			return fmt.Errorf("failed to query latest heights: %w", err)
 		}
 		return nil
-	}, retry.Context(ctx), retry.Attempts(numRetries), relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
 		r.logger.Info(
 			"Failed to query latest heights",
 			zap.String("src_chain_id", src.ChainID()),
 			zap.String("dst_chain_id", dst.ChainID()),
 			zap.Uint("attempt", n+1),
-			zap.Uint("max_attempts", numRetries),
+			zap.Uint("max_attempts", policy.Retries),
 			zap.Error(err),
 		)
 	})); err != nil {
-		return err
+		return newUpdateClientError(ErrClassTransient, err)
 	}
 
 	// generate MsgUpdateClient that carries dst header and is sent to src
-	srcMsgUpdateClient, err := r.CreateMsgUpdateClient(ctx, dst, src, dsth, srch, clientID)
+	srcMsgUpdateClient, err := r.CreateMsgUpdateClient(ctx, dst, src, dsth, srch, clientID, policy)
 	if err != nil {
 		return err
 	}
 
-	clients := &relayer.RelayMsgs{
-		Src: []provider.RelayerMessage{srcMsgUpdateClient},
-		Dst: []provider.RelayerMessage{}, // nothing will be sent to dst chain
-	}
-
 	// Send msgs to src chain in a thread-safe way
-	var result relayer.SendMsgsResult
-	krErr := r.accessKeyWithLock(func() {
-		result = clients.Send(ctx, r.logger, relayer.AsRelayMsgSender(src), relayer.AsRelayMsgSender(dst), r.cfg.Global.Memo)
-	})
-	if krErr != nil {
-		return err
-	}
-	if err := result.Error(); err != nil {
-		if result.PartiallySent() {
-			r.logger.Info(
-				"Partial success when updating clients",
-				zap.String("src_chain_id", src.ChainID()),
-				zap.String("dst_chain_id", dst.ChainID()),
-				zap.Object("send_result", result),
-			)
-		}
+	// the tx is allowed to finish within the grace period upon shutdown
+	txResp, err := r.sendUpdate(ctx, src, dst, srcMsgUpdateClient, policy)
+	if err != nil {
 		return err
 	}
 
@@ -112,18 +204,68 @@ func (r *Relayer) UpdateClient(
 		zap.String("dst_client", clientID),
 	)
 
+	// record the relayed header in DB
+	if err := r.recordUpdateSuccess(src.ChainID(), dst.ChainID(), dsth, txResp); err != nil {
+		r.logger.Warn(
+			"failed to record the client update in DB",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.Error(err),
+		)
+	}
+
 	return nil
 }
 
+// sendUpdate sends the given MsgUpdateClient of dst chain to src chain. If
+// batching is enabled for src chain, the msg is sent together with those of
+// other CZs in a single tx. Otherwise, it is sent in its own tx.
+func (r *Relayer) sendUpdate(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	msg provider.RelayerMessage,
+	policy config.RelayPolicy,
+) (*provider.RelayerTxResponse, error) {
+	if b, ok := r.batchSenders[src.ChainID()]; ok {
+		txResp, err := b.submit(ctx, dst.ChainID(), msg, policy)
+		return txResp, wrapUpdateClientError(err)
+	}
+
+	sendCtx, cancelSend := r.sendContext(ctx)
+	defer cancelSend()
+	txResp, err := r.sendMsgs(sendCtx, src, r.pickKey(src, dst.ChainID()), []string{dst.ChainID()}, []provider.RelayerMessage{msg}, policy)
+	return txResp, wrapUpdateClientError(err)
+}
+
+// KeepUpdatingClient keeps updating the IBC light client on src chain that
+// tracks dst chain, according to the given config of dst chain
 func (r *Relayer) KeepUpdatingClient(
 	ctx context.Context,
 	src *relayer.Chain,
 	dst *relayer.Chain,
-	interval time.Duration,
-	numRetries uint,
+	czCfg *config.CZConfig,
 ) error {
+	// the CZ may have upgraded to a new revision while being relayed before
+	dst, err := r.followedRevision(ctx, src, dst)
+	if err != nil {
+		return err
+	}
+
+	policy := czCfg.RelayPolicy
+	r.logger.Info(
+		"Effective relay policy",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.Object("policy", policy),
+	)
+	r.reportRelayPolicy(src.ChainID(), dst.ChainID(), policy)
+
 	// ensure the CZ chain light client exists on Babylon
-	if err := r.createClientIfNotExist(ctx, src, dst, numRetries); err != nil {
+	if err := r.createClientIfNotExist(ctx, src, dst, policy, czCfg.Client, czCfg.Upgrade); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
 		r.logger.Error(
 			"failed to ensure CZ light client exists on Babylon. Stop relaying the chain",
 			zap.String("src_chain_id", src.ChainID()),
@@ -137,91 +279,279 @@ func (r *Relayer) KeepUpdatingClient(
 		"Keep updating client",
 		zap.String("src_chain_id", src.ChainID()),
 		zap.String("dst_chain_id", dst.ChainID()),
-		zap.Duration("interval", interval),
+		zap.Duration("interval", policy.Interval),
 	)
 	r.metrics.RelayedChainsCounter.WithLabelValues(src.ChainID(), dst.ChainID()).Inc()
+	r.checkTrustWindow(ctx, src, dst, policy)
+
+	for {
+		var err error
+		if policy.Mode == config.RelayModeBlocks {
+			err = r.keepUpdatingClientOnBlocks(ctx, src, dst, policy)
+		} else {
+			err = r.keepUpdatingClientOnInterval(ctx, src, dst, policy)
+		}
+
+		// the CZ has upgraded to a new revision while being relayed, so
+		// follow it and keep relaying the new revision
+		var upgradeErr *revisionUpgradeError
+		if !errors.As(err, &upgradeErr) {
+			return err
+		}
+		if dst, err = r.followRevisionUpgrade(ctx, src, dst, upgradeErr.newChainID, czCfg); err != nil {
+			return err
+		}
+	}
+}
+
+// updateClientWithTimeout updates the client within the timeout of the given policy, if any
+func (r *Relayer) updateClientWithTimeout(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+) error {
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+	err := r.UpdateClient(ctx, src, dst, policy)
+	// an error caused by shutdown says nothing about the chains
+	if err == nil || !errors.Is(ctx.Err(), context.Canceled) {
+		r.supervisor.recordUpdate(chainPair{src: src.ChainID(), dst: dst.ChainID()}, err, time.Now())
+	}
+	return err
+}
 
-	ticker := time.NewTicker(interval)
-	for ; true; <-ticker.C {
-		// Note that UpdateClient is a thread-safe function
-		if err := r.UpdateClient(ctx, src, dst, numRetries); err != nil {
+// reportRelayPolicy exports the effective relay policy of the given chains as metrics
+func (r *Relayer) reportRelayPolicy(srcChainID string, dstChainID string, policy config.RelayPolicy) {
+	r.metrics.RelayPolicyGauge.WithLabelValues(srcChainID, dstChainID, "interval_seconds").Set(policy.Interval.Seconds())
+	r.metrics.RelayPolicyGauge.WithLabelValues(srcChainID, dstChainID, "retries").Set(float64(policy.Retries))
+	r.metrics.RelayPolicyGauge.WithLabelValues(srcChainID, dstChainID, "retry_delay_seconds").Set(policy.RetryDelay.Seconds())
+	r.metrics.RelayPolicyGauge.WithLabelValues(srcChainID, dstChainID, "timeout_seconds").Set(policy.Timeout.Seconds())
+	r.metrics.RelayPolicyGauge.WithLabelValues(srcChainID, dstChainID, "trust_fraction").Set(policy.TrustFraction)
+	if policy.Mode == config.RelayModeBlocks {
+		r.metrics.RelayPolicyGauge.WithLabelValues(srcChainID, dstChainID, "block_delta").Set(float64(policy.BlockDelta))
+		r.metrics.RelayPolicyGauge.WithLabelValues(srcChainID, dstChainID, "poll_interval_seconds").Set(policy.PollInterval.Seconds())
+	}
+}
+
+// updateClientAndHandleError updates the client and reacts to the error according
+// to its class. It only returns an error if relaying the chain has to stop.
+func (r *Relayer) updateClientAndHandleError(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+) error {
+	if r.pausedToSaveFees(src.ChainID(), dst.ChainID()) {
+		r.logger.Debug(
+			"Skip updating client of non-critical CZ while the balance is critical",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+		)
+		return nil
+	}
+
+	// Note that UpdateClient is a thread-safe function
+	err := r.updateClientWithTimeout(ctx, src, dst, policy)
+	if err == nil {
+		r.metrics.RelayedHeadersCounter.WithLabelValues(src.ChainID(), dst.ChainID()).Inc()
+		return nil
+	}
+	if ctx.Err() != nil {
+		// the error is caused by shutdown rather than the chains
+		return nil
+	}
+
+	errClass := ClassifyError(err)
+	if errClass.CountsAsFailure() {
+		if recordErr := r.recordUpdateFailure(src.ChainID(), dst.ChainID()); recordErr != nil {
+			r.logger.Warn(
+				"failed to record the client update failure in DB",
+				zap.String("src_chain_id", src.ChainID()),
+				zap.String("dst_chain_id", dst.ChainID()),
+				zap.Error(recordErr),
+			)
+		}
+	}
+	r.logger.Error(
+		"Failed to update client",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("error_class", string(errClass)),
+		zap.Error(err),
+	)
+	r.metrics.FailedHeadersCounter.WithLabelValues(src.ChainID(), dst.ChainID(), string(errClass)).Inc()
+
+	switch {
+	case errClass.Unrecoverable():
+		// the client cannot be updated anymore without operator intervention,
+		// so stop relaying the chain rather than hammering Babylon
+		r.logger.Error(
+			"CZ light client is no longer active and requires a recovery action. Stop relaying the chain",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.String("error_class", string(errClass)),
+		)
+		return err
+	case errClass == ErrClassRevisionUpgrade:
+		// the relaying loop is restarted for the new revision of the CZ that
+		// its node serves
+		return err
+	case errClass == ErrClassSequenceMismatch:
+		// resync the account state from chain and retry right away
+		// rather than waiting for another interval
+		if err := r.refreshAccount(ctx, src); err != nil {
+			r.logger.Warn(
+				"Failed to refresh account state",
+				zap.String("src_chain_id", src.ChainID()),
+				zap.Error(err),
+			)
+			return nil
+		}
+		if err := r.updateClientWithTimeout(ctx, src, dst, policy); err != nil {
 			r.logger.Error(
-				"Failed to update client",
+				"Failed to update client after refreshing account state",
 				zap.String("src_chain_id", src.ChainID()),
 				zap.String("dst_chain_id", dst.ChainID()),
 				zap.Error(err),
 			)
-			r.metrics.FailedHeadersCounter.WithLabelValues(src.ChainID(), dst.ChainID()).Inc()
-
-			// NOTE: the for loop continues here since it's possible that
-			// the endpoint of dst chain is temporarily unavailable
-			// TODO: distinguish unrecoverable errors
+			r.metrics.FailedHeadersCounter.WithLabelValues(src.ChainID(), dst.ChainID(), string(ClassifyError(err))).Inc()
 		} else {
 			r.metrics.RelayedHeadersCounter.WithLabelValues(src.ChainID(), dst.ChainID()).Inc()
 		}
+	default:
+		// NOTE: the loop continues here since it's possible that
+		// the endpoint of dst chain is temporarily unavailable, or
+		// that the account gets refunded, or the chain resumes
 	}
+
 	return nil
 }
 
+// KeepUpdatingClients keeps updating the clients of CZs enabled on each Babylon
+// chain in the given Babylon-specific config in the background. Each pair of
+// Babylon chain and CZ is relayed in its own go routine that is added to wg.
 func (r *Relayer) KeepUpdatingClients(
 	ctx context.Context,
 	wg *sync.WaitGroup,
-	babylonChainName string,
-	interval time.Duration,
-	numRetries uint,
+	babylonCfg *config.BabylonConfig,
+	supervisorCfg SupervisorConfig,
 ) {
-	// get babylonChain object from config
-	babylonChain, ok := r.cfg.Chains[babylonChainName]
-	if !ok {
-		r.logger.Error("babylon not found in config")
-		// none of the chains can be relayed without Babylon
-		return
-	}
+	// the supervisor config is only read by the go routines started below
+	r.supervisor.cfg = supervisorCfg
 
-	// ensure that key in babylonChain chain exists
-	if exists := babylonChain.ChainProvider.KeyExists(babylonChain.ChainProvider.Key()); !exists {
-		r.logger.Error(
-			"key not found on Babylon chain",
-			zap.String("key", babylonChain.ChainProvider.Key()),
+	// set up the key pools and start the batch senders before relaying any chain
+	readyChains := map[string]bool{}
+	for _, babylonChainCfg := range babylonCfg.Chains {
+		babylonChain, ok := r.cfg.Chains[babylonChainCfg.ChainName]
+		if !ok {
+			r.logger.Error("babylon not found in config", zap.String("babylon_chain_name", babylonChainCfg.ChainName))
+			// none of the chains can be relayed without Babylon
+			continue
+		}
+
+		// ensure that the keys in the pool of babylonChain exist and have their grants
+		if err := r.UseSigners(ctx, babylonChain, babylonChainCfg); err != nil {
+			r.logger.Error(
+				"failed to use signers on Babylon chain",
+				zap.String("src_chain_id", babylonChain.ChainID()),
+				zap.Error(err),
+			)
+			// none of the chains can be relayed without a keyring of Babylon
+			continue
+		}
+		readyChains[babylonChainCfg.ChainName] = true
+
+		// NOTE: the chain is relayed even if its balances cannot be watched
+		if err := r.WatchBalance(ctx, wg, babylonChain, babylonChainCfg); err != nil {
+			r.logger.Error(
+				"failed to watch balances on Babylon chain",
+				zap.String("src_chain_id", babylonChain.ChainID()),
+				zap.Error(err),
+			)
+		}
+
+		if babylonChainCfg.Batch.Window <= 0 {
+			continue
+		}
+		b := newBatchSender(r, babylonChain, babylonChainCfg.Batch)
+		r.batchSenders[babylonChain.ChainID()] = b
+		r.logger.Info(
+			"Batching client updates",
 			zap.String("src_chain_id", babylonChain.ChainID()),
+			zap.Duration("window", babylonChainCfg.Batch.Window),
+			zap.Int("max_msgs", babylonChainCfg.Batch.MaxMsgs),
 		)
-		// non of the chains can be relayed without a keyring of Babylon
-		return
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.run(ctx)
+		}()
 	}
 
-	// for each CZ (other than Babylon), start a KeepUpdatingClient go routine
-	for chainName, chain := range r.cfg.Chains {
-		if chainName == babylonChainName {
-			continue
+	for _, babylonChainCfg := range babylonCfg.Chains {
+		if readyChains[babylonChainCfg.ChainName] {
+			r.keepUpdatingClientsOnBabylon(ctx, wg, r.cfg.Chains[babylonChainCfg.ChainName], babylonChainCfg)
 		}
+	}
+}
 
+// keepUpdatingClientsOnBabylon starts a KeepUpdatingClient go routine for each
+// of the CZs enabled on the given Babylon chain
+func (r *Relayer) keepUpdatingClientsOnBabylon(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	babylonChain *relayer.Chain,
+	babylonChainCfg *config.BabylonChainConfig,
+) {
+	// for each CZ, start a KeepUpdatingClient go routine
+	for _, czCfg := range babylonChainCfg.CZs {
+		czCfg := czCfg
 		// get CZ object from config
-		czChain, err := r.cfg.Chains.Get(chain.ChainID())
-		if err != nil {
+		czChain, ok := r.cfg.Chains[czCfg.ChainName]
+		if !ok {
 			r.logger.Error(
 				"CZ chain not found in config",
-				zap.String("dst_chain_id", chain.ChainID()),
-				zap.Error(err),
+				zap.String("src_chain_id", babylonChain.ChainID()),
+				zap.String("dst_chain_name", czCfg.ChainName),
 			)
 			continue
 		}
 
+		// check the client for misbehaviour against the witnesses of the CZ, if any
+		if err := r.WatchMisbehaviour(ctx, wg, babylonChain, czChain, czCfg); err != nil {
+			r.logger.Error(
+				"failed to watch CZ light client for misbehaviour",
+				zap.String("src_chain_id", babylonChain.ChainID()),
+				zap.String("dst_chain_id", czChain.ChainID()),
+				zap.Error(err),
+			)
+		}
+
 		// ensure the czChain light client exists, then start updating the czChain light client on babylonChain
+		// the supervisor restarts the loop with backoff if it fails, e.g., when the CZ endpoint is
+		// temporarily unavailable upon startup
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 
-			// keep updating the client
-			if err := r.KeepUpdatingClient(ctx, babylonChain, czChain, interval, numRetries); err != nil {
-				// NOTE: we don't panic here since the relayer should keep relaying other chains
-				r.logger.Error(
-					"failed to update CZ chain. Stop relaying the chain",
-					zap.String("src_chain_id", babylonChain.ChainID()),
-					zap.String("dst_chain_id", czChain.ChainID()),
-					zap.Error(err),
-				)
-				r.metrics.FailedChainsCounter.WithLabelValues(babylonChain.ChainID(), czChain.ChainID()).Inc()
-			}
+			r.supervisor.supervise(ctx, babylonChain.ChainID(), czChain.ChainID(), func(ctx context.Context) error {
+				// keep updating the client
+				err := r.KeepUpdatingClient(ctx, babylonChain, czChain, czCfg)
+				if err != nil {
+					// NOTE: we don't panic here since the relayer should keep relaying other chains
+					r.logger.Error(
+						"failed to update CZ chain",
+						zap.String("src_chain_id", babylonChain.ChainID()),
+						zap.String("dst_chain_id", czChain.ChainID()),
+						zap.Error(err),
+					)
+				}
+				return err
+			})
 		}()
 	}
 }
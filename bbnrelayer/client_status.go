@@ -0,0 +1,97 @@
+package bbnrelayer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+// allClientStatuses are the statuses of a client exported as metrics
+var allClientStatuses = []ibcexported.Status{
+	ibcexported.Active,
+	ibcexported.Expired,
+	ibcexported.Frozen,
+	ibcexported.Unknown,
+	ibcexported.Unauthorized,
+}
+
+// clientStatusErrorClass returns the class of the error of updating a client
+// with the given status, or an empty class if the client can be updated
+func clientStatusErrorClass(status ibcexported.Status) ErrorClass {
+	switch status {
+	case ibcexported.Expired:
+		return ErrClassClientExpired
+	case ibcexported.Frozen:
+		return ErrClassClientFrozen
+	}
+	return ""
+}
+
+// checkClientStatus queries the status of the given client on src chain that
+// tracks dst chain, exports it and records it in DB. An unrecoverable
+// UpdateClientError is returned if the client is expired or frozen, so that
+// no tx is sent to update it. If the status cannot be queried, no error is
+// returned, as the update itself reveals an inactive client anyway.
+func (r *Relayer) checkClientStatus(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	clientID string,
+) error {
+	status, err := QueryClientStatus(ctx, src, clientID)
+	if err != nil {
+		r.logger.Debug(
+			"Failed to query status of the client before updating it",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.String("dst_client_id", clientID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	r.supervisor.recordClientStatus(chainPair{src: src.ChainID(), dst: dst.ChainID()}, clientID, status.String(), time.Now())
+
+	for _, s := range allClientStatuses {
+		value := 0.0
+		if s == status {
+			value = 1
+		}
+		r.metrics.ClientStatusGauge.WithLabelValues(src.ChainID(), dst.ChainID(), s.String()).Set(value)
+	}
+	if err := r.recordClientStatus(src.ChainID(), dst.ChainID(), status); err != nil {
+		r.logger.Warn(
+			"failed to record the client status in DB",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.Error(err),
+		)
+	}
+
+	errClass := clientStatusErrorClass(status)
+	if errClass == "" {
+		return nil
+	}
+
+	// log what the operator needs for the recovery action
+	fields := []zap.Field{
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("dst_client_id", clientID),
+		zap.String("status", status.String()),
+	}
+	if info, err := QueryClientInfo(ctx, src, clientID); err == nil {
+		fields = append(fields,
+			zap.Stringer("latest_height", info.LatestHeight),
+			zap.Stringer("frozen_height", info.FrozenHeight),
+			zap.Time("latest_consensus_timestamp", info.LatestConsensusTimestamp),
+			zap.Duration("trusting_period", info.TrustingPeriod),
+		)
+	}
+	r.logger.Error("CZ light client is not active and requires a recovery action", fields...)
+
+	return newUpdateClientError(errClass, fmt.Errorf("client %s of chain %s on chain %s is %s", clientID, dst.ChainID(), src.ChainID(), status))
+}
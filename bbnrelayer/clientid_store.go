@@ -1,47 +1,404 @@
 package bbnrelayer
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/babylonchain/babylon-relayer/config"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
-// setClientID sets the clientID for the IBC light client of a Cosmos zone
-// so that when restarting the relayer, it does not need to create another
-// IBC light client again
-// key: chainID
-// value: client ID of the given chain on Babylon
-func (r *Relayer) setClientID(chainID string, clientID string) error {
-	dbPath := config.GetDBPath(r.homePath)
-	db, err := leveldb.OpenFile(dbPath, nil)
-	if err != nil {
-		return fmt.Errorf("error opening LevelDB (%s): %w", dbPath, err)
+// ErrClientNotFound is returned by ClientStore when no client is stored for a chain
+var ErrClientNotFound = errors.New("client not found in store")
+
+// clientRecordVersion is the version of the encoding of ClientRecord.
+// Records written before versioning are plain client ID strings.
+const clientRecordVersion = 1
+
+// ClientRecord is the metadata of the IBC light client of a Cosmos zone on Babylon
+type ClientRecord struct {
+	// ClientID is the ID of the client on Babylon
+	ClientID string `json:"client_id"`
+	// BabylonChainID is the chain ID of the Babylon chain hosting the client
+	BabylonChainID string `json:"babylon_chain_id"`
+	// CreatedHeight is the Babylon height at which the client is created
+	CreatedHeight int64 `json:"created_height"`
+	// CreatedTime is the time at which the client is created
+	CreatedTime time.Time `json:"created_time"`
+	// TrustingPeriod is the trusting period of the client
+	TrustingPeriod time.Duration `json:"trusting_period"`
+	// UnbondingPeriod is the unbonding period of the client
+	UnbondingPeriod time.Duration `json:"unbonding_period"`
+	// MaxClockDrift, TrustLevel and the allow flags are the other parameters
+	// the client is created with
+	MaxClockDrift                time.Duration `json:"max_clock_drift,omitempty"`
+	TrustLevel                   string        `json:"trust_level,omitempty"`
+	AllowUpdateAfterExpiry       bool          `json:"allow_update_after_expiry,omitempty"`
+	AllowUpdateAfterMisbehaviour bool          `json:"allow_update_after_misbehaviour,omitempty"`
+	// LastRelayedHeight is the CZ height of the last successfully relayed header
+	LastRelayedHeight int64 `json:"last_relayed_height"`
+	// LastUpdateTxHash is the hash of the last successful update-client tx
+	LastUpdateTxHash string `json:"last_update_tx_hash"`
+	// LastUpdateTime is the time of the last successful update-client tx
+	LastUpdateTime time.Time `json:"last_update_time"`
+	// ConsecutiveFailures is the number of failed updates since the last successful one
+	ConsecutiveFailures uint `json:"consecutive_failures"`
+	// Status is the last known status of the client, i.e., Active, Expired or Frozen
+	Status string `json:"status,omitempty"`
+	// StatusTime is the time at which the client is found in its last known status
+	StatusTime time.Time `json:"status_time,omitempty"`
+	// SubjectClientID is the expired or frozen client that this client
+	// substitutes until it is recovered by a MsgRecoverClient
+	SubjectClientID string `json:"subject_client_id,omitempty"`
+	// Substitutions is the history of substitute clients of the CZ, oldest first
+	Substitutions []ClientSubstitution `json:"substitutions,omitempty"`
+	// Upgrades is the lineage of the client over the revisions of the CZ, oldest first
+	Upgrades []ClientUpgrade `json:"upgrades,omitempty"`
+	// Halted is the reason why the updates of the client are halted after it
+	// has been found misbehaving, which is empty unless halted
+	Halted string `json:"halted,omitempty"`
+	// HaltedTime is the time at which the client is halted
+	HaltedTime time.Time `json:"halted_time,omitempty"`
+	// MisbehaviourCheckedHeight is the latest height of the client whose
+	// consensus state has been found consistent with all witnesses
+	MisbehaviourCheckedHeight *clienttypes.Height `json:"misbehaviour_checked_height,omitempty"`
+}
+
+// versionedClientRecord is the encoding of ClientRecord in the DB
+type versionedClientRecord struct {
+	Version int `json:"version"`
+	ClientRecord
+}
+
+func encodeClientRecord(record *ClientRecord) ([]byte, error) {
+	return json.Marshal(versionedClientRecord{Version: clientRecordVersion, ClientRecord: *record})
+}
+
+// decodeClientRecord decodes a record in the DB. Records written before
+// versioning are plain client ID strings, which are decoded into records with
+// only the client ID set.
+func decodeClientRecord(bz []byte) (*ClientRecord, error) {
+	if !isVersionedClientRecord(bz) {
+		return &ClientRecord{ClientID: string(bz)}, nil
 	}
-	err = db.Put([]byte(chainID), []byte(clientID), nil)
-	db.Close()
-	if err != nil {
-		return fmt.Errorf("error writing to LevelDB (%s): %w", dbPath, err)
+	var record versionedClientRecord
+	if err := json.Unmarshal(bz, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode client record: %w", err)
+	}
+	if record.Version != clientRecordVersion {
+		return nil, fmt.Errorf("unsupported client record version %d", record.Version)
 	}
+	return &record.ClientRecord, nil
+}
 
+// isVersionedClientRecord returns whether the given value is a versioned
+// record rather than a plain client ID string. Client IDs never start with '{'.
+func isVersionedClientRecord(bz []byte) bool {
+	return len(bz) > 0 && bz[0] == '{'
+}
+
+// clientKeySeparator separates the Babylon chain ID and the CZ chain ID in
+// the keys of the DB. Chain IDs never contain it.
+const clientKeySeparator = "/"
+
+// ClientKey identifies the client of a CZ on a Babylon chain
+type ClientKey struct {
+	// BabylonChainID is empty for records written before namespacing, which
+	// are only moved under a Babylon chain ID by the operator, since they may
+	// belong to any Babylon network
+	BabylonChainID string
+	CZChainID      string
+}
+
+// String returns the key in the form of "babylon_chain_id/cz_chain_id",
+// or "cz_chain_id" for records written before namespacing
+func (k ClientKey) String() string {
+	if len(k.BabylonChainID) == 0 {
+		return k.CZChainID
+	}
+	return k.BabylonChainID + clientKeySeparator + k.CZChainID
+}
+
+// MarshalText implements encoding.TextMarshaler so that ClientKey can be used
+// as keys of JSON objects
+func (k ClientKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (k *ClientKey) UnmarshalText(text []byte) error {
+	*k = parseClientKey(string(text))
+	if len(k.CZChainID) == 0 {
+		return fmt.Errorf("invalid client key %q", text)
+	}
 	return nil
 }
 
-func (r *Relayer) getClientID(chainID string) (string, error) {
-	dbPath := config.GetDBPath(r.homePath)
+func parseClientKey(key string) ClientKey {
+	if babylonChainID, czChainID, ok := strings.Cut(key, clientKeySeparator); ok {
+		return ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}
+	}
+	return ClientKey{CZChainID: key}
+}
+
+// ClientStore stores the metadata of the IBC light client of each Cosmos zone
+// on Babylon, so that when restarting the relayer, it does not need to create
+// another IBC light client again.
+// Records are namespaced by the Babylon chain ID, so that a home used for
+// several Babylon networks never mixes up their clients. Records written
+// before namespacing are kept under the empty Babylon chain ID.
+// Implementations are safe for concurrent use.
+// key: (babylonChainID, czChainID)
+// value: record of the client of the given CZ on the given Babylon chain
+type ClientStore interface {
+	// Get returns the client record of the given CZ on the given Babylon chain, or ErrClientNotFound
+	Get(babylonChainID string, czChainID string) (*ClientRecord, error)
+	// Set sets the client record of the given CZ on the given Babylon chain
+	Set(babylonChainID string, czChainID string, record *ClientRecord) error
+	// Update applies fn to the client record of the given CZ on the given
+	// Babylon chain and writes the result back, atomically with respect to
+	// other writes. Nothing is written if fn returns an error, which is
+	// returned, or ErrClientNotFound if there is no record.
+	Update(babylonChainID string, czChainID string, fn func(record *ClientRecord) error) error
+	// Delete deletes the client record of the given CZ on the given Babylon chain
+	Delete(babylonChainID string, czChainID string) error
+	// List returns all client records
+	List() (map[ClientKey]*ClientRecord, error)
+	// Close flushes and closes the store
+	Close() error
+}
+
+// leveldbClientStore is a ClientStore backed by LevelDB
+type leveldbClientStore struct {
+	dbPath string
+	db     *leveldb.DB
+
+	// mu serializes writes, so that a read-modify-write by Update is not
+	// interleaved with other writes
+	mu sync.Mutex
+}
+
+// NewLevelDBClientStore opens the LevelDB at the given path as a ClientStore,
+// and migrates records in legacy formats to the latest one.
+// The DB remains open until the store is closed.
+func NewLevelDBClientStore(dbPath string) (ClientStore, error) {
 	db, err := leveldb.OpenFile(dbPath, nil)
 	if err != nil {
-		return "", fmt.Errorf("error opening LevelDB (%s): %w", dbPath, err)
+		return nil, fmt.Errorf("error opening LevelDB (%s), is another relayer process using it?: %w", dbPath, err)
+	}
+	s := &leveldbClientStore{dbPath: dbPath, db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewReadOnlyLevelDBClientStore opens the LevelDB at the given path as a
+// read-only ClientStore, where records in legacy formats are decoded as is,
+// and a missing DB is empty. It fails while a relayer process holds the DB.
+func NewReadOnlyLevelDBClientStore(dbPath string) (ClientStore, error) {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return NewMemClientStore(), nil
+	}
+	db, err := leveldb.OpenFile(dbPath, &opt.Options{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error opening LevelDB (%s), is another relayer process using it?: %w", dbPath, err)
+	}
+	return &leveldbClientStore{dbPath: dbPath, db: db}, nil
+}
+
+// migrate rewrites all plain client ID strings into versioned records, and
+// moves records that know their Babylon chain ID under its namespace
+func (s *leveldbClientStore) migrate() error {
+	batch := new(leveldb.Batch)
+	iter := s.db.NewIterator(nil, nil)
+	for iter.Next() {
+		key := parseClientKey(string(iter.Key()))
+		record, err := decodeClientRecord(iter.Value())
+		if err != nil {
+			iter.Release()
+			return fmt.Errorf("error decoding client record of chain %s: %w", key, err)
+		}
+		// skip records in the latest format that are either namespaced or do
+		// not know their Babylon chain ID
+		if isVersionedClientRecord(iter.Value()) && (len(key.BabylonChainID) > 0 || len(record.BabylonChainID) == 0) {
+			continue
+		}
+		bz, err := encodeClientRecord(record)
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		if len(key.BabylonChainID) == 0 && len(record.BabylonChainID) > 0 {
+			batch.Delete(append([]byte{}, iter.Key()...))
+			key.BabylonChainID = record.BabylonChainID
+		}
+		batch.Put([]byte(key.String()), bz)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("error iterating LevelDB (%s): %w", s.dbPath, err)
+	}
+	if batch.Len() == 0 {
+		return nil
 	}
-	clientID, err := db.Get([]byte(chainID), nil)
-	db.Close()
+	if err := s.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("error migrating client records in LevelDB (%s): %w", s.dbPath, err)
+	}
+	return nil
+}
 
+func (s *leveldbClientStore) get(key ClientKey) (*ClientRecord, error) {
+	bz, err := s.db.Get([]byte(key.String()), nil)
 	// distinguish not found and other errors
-	if err == leveldb.ErrNotFound {
-		return "", nil
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, ErrClientNotFound
 	} else if err != nil {
-		return "", fmt.Errorf("error reading LevelDB (%s): %w", dbPath, err)
+		return nil, fmt.Errorf("error reading LevelDB (%s): %w", s.dbPath, err)
+	}
+	return decodeClientRecord(bz)
+}
+
+func (s *leveldbClientStore) Get(babylonChainID string, czChainID string) (*ClientRecord, error) {
+	return s.get(ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID})
+}
+
+func (s *leveldbClientStore) Set(babylonChainID string, czChainID string, record *ClientRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}, record)
+}
+
+func (s *leveldbClientStore) set(key ClientKey, record *ClientRecord) error {
+	bz, err := encodeClientRecord(record)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Put([]byte(key.String()), bz, nil); err != nil {
+		return fmt.Errorf("error writing to LevelDB (%s): %w", s.dbPath, err)
 	}
+	return nil
+}
+
+func (s *leveldbClientStore) Update(babylonChainID string, czChainID string, fn func(record *ClientRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return string(clientID), nil
+	key := ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}
+	record, err := s.get(key)
+	if err != nil {
+		return err
+	}
+	if err := fn(record); err != nil {
+		return err
+	}
+	return s.set(key, record)
+}
+
+func (s *leveldbClientStore) Delete(babylonChainID string, czChainID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}
+	if err := s.db.Delete([]byte(key.String()), nil); err != nil {
+		return fmt.Errorf("error deleting from LevelDB (%s): %w", s.dbPath, err)
+	}
+	return nil
+}
+
+func (s *leveldbClientStore) List() (map[ClientKey]*ClientRecord, error) {
+	records := map[ClientKey]*ClientRecord{}
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := parseClientKey(string(iter.Key()))
+		record, err := decodeClientRecord(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("error decoding client record of chain %s: %w", key, err)
+		}
+		records[key] = record
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("error iterating LevelDB (%s): %w", s.dbPath, err)
+	}
+	return records, nil
+}
+
+func (s *leveldbClientStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("error closing LevelDB (%s): %w", s.dbPath, err)
+	}
+	return nil
+}
+
+// memClientStore is an in-memory ClientStore, mainly used for tests
+type memClientStore struct {
+	mu      sync.RWMutex
+	records map[ClientKey]ClientRecord
+}
+
+// NewMemClientStore returns an empty in-memory ClientStore
+func NewMemClientStore() ClientStore {
+	return &memClientStore{records: map[ClientKey]ClientRecord{}}
+}
+
+func (s *memClientStore) Get(babylonChainID string, czChainID string) (*ClientRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return &record, nil
+}
+
+func (s *memClientStore) Set(babylonChainID string, czChainID string, record *ClientRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}] = *record
+	return nil
+}
+
+func (s *memClientStore) Update(babylonChainID string, czChainID string, fn func(record *ClientRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}
+	record, ok := s.records[key]
+	if !ok {
+		return ErrClientNotFound
+	}
+	if err := fn(&record); err != nil {
+		return err
+	}
+	s.records[key] = record
+	return nil
+}
+
+func (s *memClientStore) Delete(babylonChainID string, czChainID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID})
+	return nil
+}
+
+func (s *memClientStore) List() (map[ClientKey]*ClientRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make(map[ClientKey]*ClientRecord, len(s.records))
+	for key, record := range s.records {
+		record := record
+		records[key] = &record
+	}
+	return records, nil
+}
+
+func (s *memClientStore) Close() error {
+	return nil
 }
@@ -0,0 +1,196 @@
+package bbnrelayer
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func TestDecodeClientRecord(t *testing.T) {
+	// records written before versioning are plain client IDs
+	record, err := decodeClientRecord([]byte("07-tendermint-0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ClientID != "07-tendermint-0" || len(record.BabylonChainID) > 0 {
+		t.Errorf("got record %+v of a plain client ID, expected only the client ID", record)
+	}
+
+	want := &ClientRecord{ClientID: "07-tendermint-1", BabylonChainID: "bbn-test-3", LastRelayedHeight: 100, Status: "Active"}
+	bz, err := encodeClientRecord(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isVersionedClientRecord(bz) {
+		t.Errorf("encoded record %s is not versioned", bz)
+	}
+	record, err = decodeClientRecord(bz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ClientID != want.ClientID || record.BabylonChainID != want.BabylonChainID ||
+		record.LastRelayedHeight != want.LastRelayedHeight || record.Status != want.Status {
+		t.Errorf("got record %+v, expected %+v", record, want)
+	}
+
+	if _, err := decodeClientRecord([]byte(`{"version":2,"client_id":"07-tendermint-1"}`)); err == nil {
+		t.Error("decoded a record of an unsupported version")
+	}
+	if _, err := decodeClientRecord([]byte(`{"version":`)); err == nil {
+		t.Error("decoded a malformed record")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	for _, key := range []ClientKey{
+		{BabylonChainID: "bbn-test-3", CZChainID: "osmo-test-5"},
+		{CZChainID: "osmo-test-5"},
+	} {
+		text, err := key.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got ClientKey
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if got != key {
+			t.Errorf("got key %+v from %q, expected %+v", got, text, key)
+		}
+	}
+	var key ClientKey
+	if err := key.UnmarshalText([]byte("bbn-test-3/")); err == nil {
+		t.Error("unmarshalled a key without a CZ chain ID")
+	}
+}
+
+func TestLevelDBClientStoreMigrate(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "client-ids.db")
+	db, err := leveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scoped, err := encodeClientRecord(&ClientRecord{ClientID: "07-tendermint-2", BabylonChainID: "bbn-test-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string][]byte{
+		// a plain client ID may belong to any Babylon network, so it is left unscoped
+		"osmo-test-5": []byte("07-tendermint-0"),
+		// a plain client ID under a Babylon chain ID is only rewritten
+		"bbn-test-3/juno-1": []byte("07-tendermint-1"),
+		// a record that knows its Babylon chain ID is moved under it
+		"cz-1": scoped,
+	} {
+		if err := db.Put([]byte(key), value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewLevelDBClientStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[ClientKey]string{
+		{CZChainID: "osmo-test-5"}:                          "07-tendermint-0",
+		{BabylonChainID: "bbn-test-3", CZChainID: "juno-1"}: "07-tendermint-1",
+		{BabylonChainID: "bbn-test-3", CZChainID: "cz-1"}:   "07-tendermint-2",
+	}
+	if len(records) != len(want) {
+		t.Errorf("got %d records after migration, expected %d", len(records), len(want))
+	}
+	for key, clientID := range want {
+		if record, ok := records[key]; !ok || record.ClientID != clientID {
+			t.Errorf("got record %+v under key %s, expected client %s", record, key, clientID)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every record is versioned after migration
+	db, err = leveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if !isVersionedClientRecord(iter.Value()) {
+			t.Errorf("record %s is not versioned after migration", iter.Key())
+		}
+	}
+}
+
+func TestClientStoreUpdate(t *testing.T) {
+	leveldbStore, err := NewLevelDBClientStore(filepath.Join(t.TempDir(), "client-ids.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leveldbStore.Close()
+
+	for name, store := range map[string]ClientStore{
+		"leveldb": leveldbStore,
+		"mem":     NewMemClientStore(),
+	} {
+		err := store.Update("bbn-test-3", "osmo-test-5", func(record *ClientRecord) error { return nil })
+		if !errors.Is(err, ErrClientNotFound) {
+			t.Errorf("%s: got error %v upon updating a missing record, expected %v", name, err, ErrClientNotFound)
+		}
+
+		if err := store.Set("bbn-test-3", "osmo-test-5", &ClientRecord{ClientID: "07-tendermint-0"}); err != nil {
+			t.Fatal(err)
+		}
+		// nothing is written if fn fails
+		errFailed := errors.New("failed")
+		err = store.Update("bbn-test-3", "osmo-test-5", func(record *ClientRecord) error {
+			record.ClientID = "07-tendermint-1"
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Errorf("%s: got error %v, expected the error of fn", name, err)
+		}
+
+		// concurrent updates are not lost
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := store.Update("bbn-test-3", "osmo-test-5", func(record *ClientRecord) error {
+					record.ConsecutiveFailures++
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		record, err := store.Get("bbn-test-3", "osmo-test-5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.ClientID != "07-tendermint-0" || record.ConsecutiveFailures != 50 {
+			t.Errorf("%s: got client %s with %d failures, expected 07-tendermint-0 with 50 failures", name, record.ClientID, record.ConsecutiveFailures)
+		}
+
+		if err := store.Delete("bbn-test-3", "osmo-test-5"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Get("bbn-test-3", "osmo-test-5"); !errors.Is(err, ErrClientNotFound) {
+			t.Errorf("%s: got error %v after deletion, expected %v", name, err, ErrClientNotFound)
+		}
+	}
+}
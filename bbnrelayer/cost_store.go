@@ -0,0 +1,231 @@
+package bbnrelayer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// CostRecord is the share of a CZ in the gas and fees of a tx sent to Babylon
+type CostRecord struct {
+	// BabylonChainID is the chain ID of the Babylon chain the tx is sent to
+	BabylonChainID string `json:"babylon_chain_id"`
+	// CZChainID is the chain ID of the CZ the msgs belong to
+	CZChainID string `json:"cz_chain_id"`
+	// Time is the time at which the tx is included
+	Time time.Time `json:"time"`
+	// TxHash is the hash of the tx
+	TxHash string `json:"tx_hash"`
+	// Height is the Babylon height at which the tx is included
+	Height int64 `json:"height"`
+	// Msgs is the number of msgs of the CZ in the tx
+	Msgs int `json:"msgs"`
+	// TxMsgs is the number of msgs in the tx, among which the costs are split
+	TxMsgs int `json:"tx_msgs"`
+	// Failed is whether the tx failed, in which case the fees are paid anyway
+	Failed bool `json:"failed,omitempty"`
+	// GasUsed is the share of the CZ in the gas used by the tx
+	GasUsed int64 `json:"gas_used"`
+	// GasWanted is the share of the CZ in the gas limit of the tx
+	GasWanted int64 `json:"gas_wanted"`
+	// Fees is the share of the CZ in the fees of the tx
+	Fees sdk.Coins `json:"fees"`
+}
+
+// CostSummary is the total costs of a CZ on a Babylon chain within a period
+type CostSummary struct {
+	BabylonChainID string    `json:"babylon_chain_id"`
+	CZChainID      string    `json:"cz_chain_id"`
+	Txs            int       `json:"txs"`
+	FailedTxs      int       `json:"failed_txs"`
+	Msgs           int       `json:"msgs"`
+	GasUsed        int64     `json:"gas_used"`
+	GasWanted      int64     `json:"gas_wanted"`
+	Fees           sdk.Coins `json:"fees"`
+	FirstTime      time.Time `json:"first_time"`
+	LastTime       time.Time `json:"last_time"`
+}
+
+// SummarizeCosts sums up the given records per Babylon chain and CZ, sorted
+// by Babylon chain ID and then CZ chain ID
+func SummarizeCosts(records []*CostRecord) []*CostSummary {
+	summaries := map[ClientKey]*CostSummary{}
+	for _, record := range records {
+		key := ClientKey{BabylonChainID: record.BabylonChainID, CZChainID: record.CZChainID}
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &CostSummary{
+				BabylonChainID: record.BabylonChainID,
+				CZChainID:      record.CZChainID,
+				Fees:           sdk.NewCoins(),
+				FirstTime:      record.Time,
+			}
+			summaries[key] = summary
+		}
+		summary.Txs++
+		if record.Failed {
+			summary.FailedTxs++
+		}
+		summary.Msgs += record.Msgs
+		summary.GasUsed += record.GasUsed
+		summary.GasWanted += record.GasWanted
+		summary.Fees = summary.Fees.Add(record.Fees...)
+		if record.Time.Before(summary.FirstTime) {
+			summary.FirstTime = record.Time
+		}
+		if record.Time.After(summary.LastTime) {
+			summary.LastTime = record.Time
+		}
+	}
+
+	sorted := make([]*CostSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		sorted = append(sorted, summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].BabylonChainID != sorted[j].BabylonChainID {
+			return sorted[i].BabylonChainID < sorted[j].BabylonChainID
+		}
+		return sorted[i].CZChainID < sorted[j].CZChainID
+	})
+	return sorted
+}
+
+// CostStore stores the history of the costs of each CZ on Babylon, so that
+// the spend per chain can be reported afterwards.
+// Implementations are safe for concurrent use.
+type CostStore interface {
+	// Add adds the given record to the history
+	Add(record *CostRecord) error
+	// List returns the records since the given time in chronological order
+	List(since time.Time) ([]*CostRecord, error)
+	// Close flushes and closes the store
+	Close() error
+}
+
+// costKey returns the key of the given record in the DB, which starts with
+// the time of the record so that records are iterated in chronological order
+func costKey(record *CostRecord) []byte {
+	return []byte(strings.Join([]string{costTimeKey(record.Time), record.BabylonChainID, record.CZChainID, record.TxHash}, clientKeySeparator))
+}
+
+// costTimeKey encodes the given time into a prefix of keys that sorts in
+// chronological order
+func costTimeKey(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+// leveldbCostStore is a CostStore backed by LevelDB
+type leveldbCostStore struct {
+	dbPath string
+	db     *leveldb.DB
+}
+
+// NewLevelDBCostStore opens the LevelDB at the given path as a CostStore.
+// The DB remains open until the store is closed.
+func NewLevelDBCostStore(dbPath string) (CostStore, error) {
+	db, err := leveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error opening LevelDB (%s), is another relayer process using it?: %w", dbPath, err)
+	}
+	return &leveldbCostStore{dbPath: dbPath, db: db}, nil
+}
+
+// NewReadOnlyLevelDBCostStore opens the LevelDB at the given path as a
+// read-only CostStore, where a missing DB is empty. It fails while a relayer
+// process holds the DB.
+func NewReadOnlyLevelDBCostStore(dbPath string) (CostStore, error) {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return NewMemCostStore(), nil
+	}
+	db, err := leveldb.OpenFile(dbPath, &opt.Options{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error opening LevelDB (%s), is another relayer process using it?: %w", dbPath, err)
+	}
+	return &leveldbCostStore{dbPath: dbPath, db: db}, nil
+}
+
+func (s *leveldbCostStore) Add(record *CostRecord) error {
+	bz, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Put(costKey(record), bz, nil); err != nil {
+		return fmt.Errorf("error writing to LevelDB (%s): %w", s.dbPath, err)
+	}
+	return nil
+}
+
+func (s *leveldbCostStore) List(since time.Time) ([]*CostRecord, error) {
+	var records []*CostRecord
+	iter := s.db.NewIterator(&util.Range{Start: []byte(costTimeKey(since))}, nil)
+	defer iter.Release()
+	for iter.Next() {
+		var record CostRecord
+		if err := json.Unmarshal(iter.Value(), &record); err != nil {
+			return nil, fmt.Errorf("error decoding cost record %s: %w", iter.Key(), err)
+		}
+		records = append(records, &record)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("error iterating LevelDB (%s): %w", s.dbPath, err)
+	}
+	return records, nil
+}
+
+func (s *leveldbCostStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("error closing LevelDB (%s): %w", s.dbPath, err)
+	}
+	return nil
+}
+
+// memCostStore is an in-memory CostStore, mainly used for tests
+type memCostStore struct {
+	mu      sync.RWMutex
+	records []CostRecord
+}
+
+// NewMemCostStore returns an empty in-memory CostStore
+func NewMemCostStore() CostStore {
+	return &memCostStore{}
+}
+
+func (s *memCostStore) Add(record *CostRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.records), func(i int) bool {
+		return s.records[i].Time.After(record.Time)
+	})
+	s.records = append(s.records, CostRecord{})
+	copy(s.records[i+1:], s.records[i:])
+	s.records[i] = *record
+	return nil
+}
+
+func (s *memCostStore) List(since time.Time) ([]*CostRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []*CostRecord
+	for _, record := range s.records {
+		if !record.Time.Before(since) {
+			record := record
+			records = append(records, &record)
+		}
+	}
+	return records, nil
+}
+
+func (s *memCostStore) Close() error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package bbnrelayer
+
+import (
+	"math/big"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+// splitCosts splits the gas and fees of the given tx among the CZs of its
+// msgs in proportion to their numbers of msgs, where dstChainIDs[i] is the
+// CZ of the i-th msg. Shares are rounded down.
+func splitCosts(babylonChainID string, dstChainIDs []string, res *sdk.TxResponse, fees sdk.Coins) []*CostRecord {
+	txTime, err := time.Parse(time.RFC3339, res.Timestamp)
+	if err != nil {
+		txTime = time.Now()
+	}
+
+	var (
+		records []*CostRecord
+		indices = map[string]int{}
+	)
+	for _, dstChainID := range dstChainIDs {
+		i, ok := indices[dstChainID]
+		if !ok {
+			i = len(records)
+			indices[dstChainID] = i
+			records = append(records, &CostRecord{
+				BabylonChainID: babylonChainID,
+				CZChainID:      dstChainID,
+				Time:           txTime.UTC(),
+				TxHash:         res.TxHash,
+				Height:         res.Height,
+				TxMsgs:         len(dstChainIDs),
+				Failed:         res.Code != 0,
+			})
+		}
+		records[i].Msgs++
+	}
+
+	n := int64(len(dstChainIDs))
+	for _, record := range records {
+		share := int64(record.Msgs)
+		record.GasUsed = res.GasUsed * share / n
+		record.GasWanted = res.GasWanted * share / n
+		record.Fees = sdk.NewCoins()
+		for _, fee := range fees {
+			record.Fees = record.Fees.Add(sdk.NewCoin(fee.Denom, fee.Amount.MulRaw(share).QuoRaw(n)))
+		}
+	}
+	return records
+}
+
+// recordTxCosts attributes the costs of the given tx sent to src chain to
+// the CZs of its msgs, exports them as metrics and adds them to the history
+func (r *Relayer) recordTxCosts(src *relayer.Chain, dstChainIDs []string, res *sdk.TxResponse, fees sdk.Coins) {
+	if len(dstChainIDs) == 0 {
+		return
+	}
+	for _, record := range splitCosts(src.ChainID(), dstChainIDs, res, fees) {
+		r.metrics.GasUsedCounter.WithLabelValues(record.BabylonChainID, record.CZChainID).Add(float64(record.GasUsed))
+		r.metrics.GasWantedCounter.WithLabelValues(record.BabylonChainID, record.CZChainID).Add(float64(record.GasWanted))
+		r.metrics.GasUsedHistogram.WithLabelValues(record.BabylonChainID, record.CZChainID).Observe(float64(record.GasUsed))
+		for _, fee := range record.Fees {
+			amount, _ := new(big.Float).SetInt(fee.Amount.BigInt()).Float64()
+			r.metrics.FeesPaidCounter.WithLabelValues(record.BabylonChainID, record.CZChainID, fee.Denom).Add(amount)
+			r.metrics.FeesPaidHistogram.WithLabelValues(record.BabylonChainID, record.CZChainID, fee.Denom).Observe(amount)
+		}
+
+		if err := r.costStore.Add(record); err != nil {
+			r.logger.Warn(
+				"failed to record the costs of tx in DB",
+				zap.String("src_chain_id", record.BabylonChainID),
+				zap.String("dst_chain_id", record.CZChainID),
+				zap.String("tx_hash", record.TxHash),
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package bbnrelayer
+
+import (
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestSplitCosts(t *testing.T) {
+	res := &sdk.TxResponse{
+		TxHash:    "TXHASH",
+		Height:    100,
+		Code:      5,
+		GasUsed:   1000,
+		GasWanted: 2000,
+		Timestamp: "2024-01-02T03:04:05Z",
+	}
+	fees := sdk.NewCoins(sdk.NewCoin("ubbn", sdkmath.NewInt(301)), sdk.NewCoin("uother", sdkmath.NewInt(3)))
+
+	records := splitCosts("bbn-test-3", []string{"cz-0", "cz-1", "cz-0"}, res, fees)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, expected one per CZ", len(records))
+	}
+	want := []struct {
+		czChainID string
+		msgs      int
+		gasUsed   int64
+		gasWanted int64
+		fees      sdk.Coins
+	}{
+		// shares are rounded down
+		{"cz-0", 2, 666, 1333, sdk.NewCoins(sdk.NewCoin("ubbn", sdkmath.NewInt(200)), sdk.NewCoin("uother", sdkmath.NewInt(2)))},
+		{"cz-1", 1, 333, 666, sdk.NewCoins(sdk.NewCoin("ubbn", sdkmath.NewInt(100)), sdk.NewCoin("uother", sdkmath.NewInt(1)))},
+	}
+	txTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, record := range records {
+		w := want[i]
+		if record.CZChainID != w.czChainID || record.Msgs != w.msgs || record.TxMsgs != 3 {
+			t.Errorf("got %d of %d msgs of %s, expected %d of 3 msgs of %s", record.Msgs, record.TxMsgs, record.CZChainID, w.msgs, w.czChainID)
+		}
+		if record.GasUsed != w.gasUsed || record.GasWanted != w.gasWanted {
+			t.Errorf("%s: got gas used %d and wanted %d, expected %d and %d", w.czChainID, record.GasUsed, record.GasWanted, w.gasUsed, w.gasWanted)
+		}
+		if !record.Fees.Equal(w.fees) {
+			t.Errorf("%s: got fees %s, expected %s", w.czChainID, record.Fees, w.fees)
+		}
+		if record.BabylonChainID != "bbn-test-3" || record.TxHash != "TXHASH" || record.Height != 100 {
+			t.Errorf("%s: got tx %s at %s/%d, expected TXHASH at bbn-test-3/100", w.czChainID, record.TxHash, record.BabylonChainID, record.Height)
+		}
+		if !record.Time.Equal(txTime) {
+			t.Errorf("%s: got time %v, expected the time of the tx %v", w.czChainID, record.Time, txTime)
+		}
+		// the fees of a failed tx are paid anyway
+		if !record.Failed {
+			t.Errorf("%s: the costs of a failed tx are not marked as failed", w.czChainID)
+		}
+	}
+
+	// the costs of a tx without a timestamp are recorded at the current time
+	before := time.Now()
+	records = splitCosts("bbn-test-3", []string{"cz-0"}, &sdk.TxResponse{GasUsed: 1000}, nil)
+	if len(records) != 1 || records[0].GasUsed != 1000 || !records[0].Fees.IsZero() || records[0].Failed {
+		t.Errorf("got records %+v of a single msg, expected all costs of the tx", records)
+	}
+	if len(records) == 1 && records[0].Time.Before(before) {
+		t.Errorf("got time %v of a tx without a timestamp, expected the current time", records[0].Time)
+	}
+}
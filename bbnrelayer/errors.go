@@ -0,0 +1,137 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+)
+
+// ErrorClass is the class of an error that occurred when updating a client.
+// It determines how the relaying loop reacts to the error.
+type ErrorClass string
+
+const (
+	// ErrClassTransient is a temporary failure, e.g., an unavailable RPC endpoint
+	ErrClassTransient ErrorClass = "transient"
+	// ErrClassInsufficientFunds means the relayer account cannot afford the fees
+	ErrClassInsufficientFunds ErrorClass = "insufficient_funds"
+	// ErrClassSequenceMismatch means the account sequence used for signing is stale
+	ErrClassSequenceMismatch ErrorClass = "sequence_mismatch"
+	// ErrClassClientExpired means the client has passed its trusting period
+	ErrClassClientExpired ErrorClass = "client_expired"
+	// ErrClassClientFrozen means the client has been frozen due to misbehaviour
+	ErrClassClientFrozen ErrorClass = "client_frozen"
+	// ErrClassHeaderVerification means the header cannot be verified by the client
+	ErrClassHeaderVerification ErrorClass = "header_verification"
+	// ErrClassChainHalted means the CZ has not produced new blocks since the last update
+	ErrClassChainHalted ErrorClass = "chain_halted"
+	// ErrClassRevisionUpgrade means the CZ has bumped its revision number
+	ErrClassRevisionUpgrade ErrorClass = "revision_upgrade"
+	// ErrClassClientMismatch means the stored client tracks another chain than the CZ
+	ErrClassClientMismatch ErrorClass = "client_mismatch"
+	// ErrClassMisbehaviour means the client holds a header that conflicts with
+	// the header of an independent node of the CZ
+	ErrClassMisbehaviour ErrorClass = "misbehaviour"
+)
+
+// Unrecoverable returns whether the relayer cannot recover from an error of
+// this class without operator intervention
+func (c ErrorClass) Unrecoverable() bool {
+	return c == ErrClassClientExpired || c == ErrClassClientFrozen || c == ErrClassClientMismatch || c == ErrClassMisbehaviour
+}
+
+// CountsAsFailure returns whether an error of this class counts as a
+// consecutive failure, which schedules the next update sooner. A halted CZ has
+// no new header to relay, so updating sooner would only waste queries.
+func (c ErrorClass) CountsAsFailure() bool {
+	return c != ErrClassChainHalted
+}
+
+// UpdateClientError is an error that occurred when updating a client,
+// annotated with its class
+type UpdateClientError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *UpdateClientError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Class, e.Err)
+}
+
+func (e *UpdateClientError) Unwrap() error {
+	return e.Err
+}
+
+// newUpdateClientError wraps the given error into an UpdateClientError of the
+// given class. If the error is already an UpdateClientError, it is returned as is.
+func newUpdateClientError(class ErrorClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	var updateErr *UpdateClientError
+	if errors.As(err, &updateErr) {
+		return err
+	}
+	return &UpdateClientError{Class: class, Err: err}
+}
+
+// wrapUpdateClientError classifies the given error and wraps it into an UpdateClientError
+func wrapUpdateClientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return newUpdateClientError(ClassifyError(err), err)
+}
+
+// ClassifyError returns the class of the given error. Errors returned by the
+// chain are usually flattened into strings after broadcasting, so apart from
+// errors.Is/As we also match the error messages of the registered errors.
+func ClassifyError(err error) ErrorClass {
+	var updateErr *UpdateClientError
+	if errors.As(err, &updateErr) {
+		return updateErr.Class
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrClassTransient
+	}
+
+	msg := err.Error()
+	// NOTE: a client that is not active reports its status in the error message,
+	// e.g., "cannot update client (07-tendermint-0) with status Expired: client state is not active"
+	switch {
+	case errorMatches(err, clienttypes.ErrClientFrozen) ||
+		(errorMatches(err, clienttypes.ErrClientNotActive) && strings.Contains(msg, "Frozen")):
+		return ErrClassClientFrozen
+	case errorMatches(err, ibctm.ErrTrustingPeriodExpired) ||
+		errorMatches(err, ibctm.ErrUnbondingPeriodExpired) ||
+		(errorMatches(err, clienttypes.ErrClientNotActive) && strings.Contains(msg, "Expired")):
+		return ErrClassClientExpired
+	case errorMatches(err, sdkerrors.ErrWrongSequence) || strings.Contains(msg, "account sequence mismatch"):
+		return ErrClassSequenceMismatch
+	case errorMatches(err, sdkerrors.ErrInsufficientFunds) || errorMatches(err, sdkerrors.ErrInsufficientFee):
+		return ErrClassInsufficientFunds
+	case errorMatches(err, clienttypes.ErrInvalidHeader) ||
+		errorMatches(err, ibctm.ErrInvalidHeader) ||
+		errorMatches(err, ibctm.ErrInvalidHeaderHeight) ||
+		errorMatches(err, ibctm.ErrInvalidValidatorSet):
+		return ErrClassHeaderVerification
+	}
+
+	return ErrClassTransient
+}
+
+// errorMatches returns whether err is or carries the message of the given registered error
+func errorMatches(err error, target error) bool {
+	return errors.Is(err, target) || strings.Contains(err.Error(), target.Error())
+}
+
+// IsUnrecoverable returns whether the given error is one that the relayer
+// cannot recover from without operator intervention
+func IsUnrecoverable(err error) bool {
+	return err != nil && ClassifyError(err).Unrecoverable()
+}
@@ -0,0 +1,129 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{
+			name: "unknown error",
+			err:  errors.New("connection refused"),
+			want: ErrClassTransient,
+		},
+		{
+			name: "cancelled context",
+			err:  fmt.Errorf("failed to query: %w", context.Canceled),
+			want: ErrClassTransient,
+		},
+		{
+			name: "classified error",
+			err:  fmt.Errorf("wrapped: %w", newUpdateClientError(ErrClassChainHalted, errors.New("no new block"))),
+			want: ErrClassChainHalted,
+		},
+		{
+			name: "frozen client",
+			err:  clienttypes.ErrClientFrozen,
+			want: ErrClassClientFrozen,
+		},
+		{
+			name: "inactive client with status Frozen",
+			err:  errors.New("cannot update client (07-tendermint-0) with status Frozen: " + clienttypes.ErrClientNotActive.Error()),
+			want: ErrClassClientFrozen,
+		},
+		{
+			name: "inactive client with status Expired",
+			err:  errors.New("cannot update client (07-tendermint-0) with status Expired: " + clienttypes.ErrClientNotActive.Error()),
+			want: ErrClassClientExpired,
+		},
+		{
+			name: "trusting period expired",
+			err:  fmt.Errorf("failed to verify header: %w", ibctm.ErrTrustingPeriodExpired),
+			want: ErrClassClientExpired,
+		},
+		{
+			name: "wrong sequence",
+			err:  sdkerrors.ErrWrongSequence,
+			want: ErrClassSequenceMismatch,
+		},
+		{
+			name: "flattened account sequence mismatch",
+			err:  errors.New("account sequence mismatch, expected 10, got 9: incorrect account sequence"),
+			want: ErrClassSequenceMismatch,
+		},
+		{
+			name: "flattened insufficient funds",
+			err:  errors.New("transaction failed to execute: " + sdkerrors.ErrInsufficientFunds.Error()),
+			want: ErrClassInsufficientFunds,
+		},
+		{
+			name: "insufficient fee",
+			err:  sdkerrors.ErrInsufficientFee,
+			want: ErrClassInsufficientFunds,
+		},
+		{
+			name: "invalid header",
+			err:  fmt.Errorf("failed to update client: %w", ibctm.ErrInvalidHeader),
+			want: ErrClassHeaderVerification,
+		},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("%s: ClassifyError(%q) = %s, expected %s", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWrapUpdateClientError(t *testing.T) {
+	if err := wrapUpdateClientError(nil); err != nil {
+		t.Errorf("wrapUpdateClientError(nil) = %v, expected nil", err)
+	}
+
+	// the class of an already classified error is kept
+	classified := newUpdateClientError(ErrClassClientMismatch, sdkerrors.ErrWrongSequence)
+	err := wrapUpdateClientError(classified)
+	if err != classified {
+		t.Errorf("wrapUpdateClientError re-wrapped a classified error into %v", err)
+	}
+
+	var updateErr *UpdateClientError
+	err = wrapUpdateClientError(sdkerrors.ErrInsufficientFunds)
+	if !errors.As(err, &updateErr) || updateErr.Class != ErrClassInsufficientFunds {
+		t.Errorf("wrapUpdateClientError(%v) = %v, expected an error of class %s", sdkerrors.ErrInsufficientFunds, err, ErrClassInsufficientFunds)
+	}
+	if !errors.Is(err, sdkerrors.ErrInsufficientFunds) {
+		t.Errorf("wrapped error %v does not unwrap to %v", err, sdkerrors.ErrInsufficientFunds)
+	}
+}
+
+func TestErrorClassUnrecoverable(t *testing.T) {
+	unrecoverable := map[ErrorClass]bool{
+		ErrClassClientExpired:  true,
+		ErrClassClientFrozen:   true,
+		ErrClassClientMismatch: true,
+		ErrClassMisbehaviour:   true,
+	}
+	for _, class := range []ErrorClass{
+		ErrClassTransient, ErrClassInsufficientFunds, ErrClassSequenceMismatch, ErrClassClientExpired,
+		ErrClassClientFrozen, ErrClassHeaderVerification, ErrClassChainHalted, ErrClassRevisionUpgrade,
+		ErrClassClientMismatch, ErrClassMisbehaviour,
+	} {
+		if got := class.Unrecoverable(); got != unrecoverable[class] {
+			t.Errorf("%s.Unrecoverable() = %v, expected %v", class, got, unrecoverable[class])
+		}
+	}
+	if IsUnrecoverable(nil) {
+		t.Error("IsUnrecoverable(nil) = true, expected false")
+	}
+}
@@ -0,0 +1,160 @@
+package bbnrelayer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cosmossdk.io/x/feegrant"
+	"github.com/babylonchain/babylon-relayer/config"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	"github.com/cosmos/relayer/v2/relayer"
+)
+
+// kinds of grants to the keys that sign client updates
+const (
+	GrantKindFeegrant = "feegrant"
+	GrantKindAuthz    = "authz"
+)
+
+// authzMsgTypeURLs are the msgs that the keys execute on behalf of the authz
+// granter, i.e., every msg the relayer sends to Babylon: clients are created
+// for new CZs and for substitutes, updated, upgraded to new revisions of CZs,
+// and frozen by misbehaviour. MsgRecoverClient is executed by governance.
+var authzMsgTypeURLs = []string{
+	sdk.MsgTypeURL(&clienttypes.MsgCreateClient{}),
+	sdk.MsgTypeURL(&clienttypes.MsgUpdateClient{}),
+	sdk.MsgTypeURL(&clienttypes.MsgUpgradeClient{}),
+	sdk.MsgTypeURL(&clienttypes.MsgSubmitMisbehaviour{}), //nolint:staticcheck
+}
+
+// GrantStatus is the status of a grant to a key that signs client updates
+type GrantStatus struct {
+	Kind    string `json:"kind"`
+	Key     string `json:"key"`
+	Granter string `json:"granter"`
+	Grantee string `json:"grantee"`
+	// MsgTypeURL is the msg authorised by an authz grant
+	MsgTypeURL string     `json:"msg_type_url,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	// Error is the reason why the grant is not usable, if any
+	Error string `json:"error,omitempty"`
+}
+
+// CheckGrants queries the given grants to each of the given keys on the given
+// chain, and returns the status of each grant. An error is returned if any
+// grant does not exist or has expired.
+func CheckGrants(
+	ctx context.Context,
+	chain *relayer.Chain,
+	keys []string,
+	grants config.GrantsConfig,
+) ([]GrantStatus, error) {
+	if len(grants.FeeGranter) == 0 && len(grants.AuthzGranter) == 0 {
+		return nil, nil
+	}
+	cc, err := cosmosProvider(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		statuses []GrantStatus
+		failed   int
+	)
+	now := time.Now()
+	for _, key := range keys {
+		addr, err := cc.GetKeyAddressForKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get address of key %s: %w", key, err)
+		}
+		grantee, err := cc.EncodeBech32AccAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode address of key %s: %w", key, err)
+		}
+
+		if len(grants.FeeGranter) > 0 {
+			status := GrantStatus{Kind: GrantKindFeegrant, Key: key, Granter: grants.FeeGranter, Grantee: grantee}
+			status.Expiration, err = queryFeeAllowanceExpiration(ctx, chain, grants.FeeGranter, grantee)
+			if err == nil && status.Expiration != nil && status.Expiration.Before(now) {
+				err = fmt.Errorf("fee allowance expired at %v", status.Expiration)
+			}
+			if err != nil {
+				status.Error = err.Error()
+				failed++
+			}
+			statuses = append(statuses, status)
+		}
+
+		if len(grants.AuthzGranter) > 0 {
+			for _, msgTypeURL := range authzMsgTypeURLs {
+				status := GrantStatus{Kind: GrantKindAuthz, Key: key, Granter: grants.AuthzGranter, Grantee: grantee, MsgTypeURL: msgTypeURL}
+				status.Expiration, err = queryAuthzGrantExpiration(ctx, chain, grants.AuthzGranter, grantee, msgTypeURL)
+				if err == nil && status.Expiration != nil && status.Expiration.Before(now) {
+					err = fmt.Errorf("authz grant expired at %v", status.Expiration)
+				}
+				if err != nil {
+					status.Error = err.Error()
+					failed++
+				}
+				statuses = append(statuses, status)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return statuses, fmt.Errorf("%d of %d grants on chain %s are missing or expired", failed, len(statuses), chain.ChainID())
+	}
+	return statuses, nil
+}
+
+// queryFeeAllowanceExpiration queries the fee allowance from granter to grantee
+// on the given chain, and returns its expiration, which is nil if it never expires
+func queryFeeAllowanceExpiration(ctx context.Context, chain *relayer.Chain, granter string, grantee string) (*time.Time, error) {
+	cc, err := cosmosProvider(chain)
+	if err != nil {
+		return nil, err
+	}
+	res, err := feegrant.NewQueryClient(cc).Allowance(ctx, &feegrant.QueryAllowanceRequest{Granter: granter, Grantee: grantee})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fee allowance: %w", err)
+	}
+	if res.Allowance == nil || res.Allowance.Allowance == nil {
+		return nil, fmt.Errorf("fee allowance not found")
+	}
+	var allowance feegrant.FeeAllowanceI
+	if err := cc.Cdc.InterfaceRegistry.UnpackAny(res.Allowance.Allowance, &allowance); err != nil {
+		return nil, fmt.Errorf("failed to unpack fee allowance: %w", err)
+	}
+	return allowance.ExpiresAt()
+}
+
+// queryAuthzGrantExpiration queries the authz grant from granter to grantee
+// for the given msg on the given chain, and returns its expiration, which is
+// nil if it never expires
+func queryAuthzGrantExpiration(ctx context.Context, chain *relayer.Chain, granter string, grantee string, msgTypeURL string) (*time.Time, error) {
+	cc, err := cosmosProvider(chain)
+	if err != nil {
+		return nil, err
+	}
+	res, err := authz.NewQueryClient(cc).Grants(ctx, &authz.QueryGrantsRequest{Granter: granter, Grantee: grantee, MsgTypeUrl: msgTypeURL})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query authz grants: %w", err)
+	}
+	if len(res.Grants) == 0 {
+		return nil, fmt.Errorf("authz grant not found")
+	}
+	// the grant that expires the latest is used
+	var expiration *time.Time
+	for i, grant := range res.Grants {
+		if grant.Expiration == nil {
+			return nil, nil
+		}
+		if i == 0 || grant.Expiration.After(*expiration) {
+			expiration = grant.Expiration
+		}
+	}
+	return expiration, nil
+}
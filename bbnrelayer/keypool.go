@@ -0,0 +1,150 @@
+package bbnrelayer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+// keyPool is the pool of keys that sign the client updates sent to a Babylon
+// chain. Each key has its own sequence manager, so that client updates signed
+// by different keys do not contend for account sequences.
+type keyPool struct {
+	strategy config.KeyStrategy
+	keys     []string
+	// pinned is the key of each CZ that is pinned to a key, keyed by chain ID
+	pinned map[string]string
+
+	mu sync.Mutex
+	// next is the index of the next key in the round-robin strategy
+	next int
+	// lastUsed is the last time each key is picked in the least-recently-used strategy
+	lastUsed map[string]time.Time
+}
+
+// newKeyPool creates the key pool of the given Babylon chain according to its
+// config. CZs are pinned to the keys specified in their config, and to the
+// keys in turn in the pinned strategy.
+func newKeyPool(
+	babylonChain *relayer.Chain,
+	babylonChainCfg *config.BabylonChainConfig,
+	chains relayer.Chains,
+) *keyPool {
+	p := &keyPool{
+		strategy: babylonChainCfg.Keys.Strategy,
+		keys:     babylonChainCfg.KeyNames(babylonChain.ChainProvider.Key()),
+		pinned:   map[string]string{},
+		lastUsed: map[string]time.Time{},
+	}
+	if p.strategy == "" {
+		p.strategy = config.KeyStrategyRoundRobin
+	}
+
+	i := 0
+	for _, czCfg := range babylonChainCfg.CZs {
+		czChain, ok := chains[czCfg.ChainName]
+		if !ok {
+			continue
+		}
+		switch {
+		case len(czCfg.Key) > 0:
+			p.pinned[czChain.ChainID()] = czCfg.Key
+		case p.strategy == config.KeyStrategyPinned:
+			p.pinned[czChain.ChainID()] = p.keys[i%len(p.keys)]
+			i++
+		}
+	}
+	return p
+}
+
+// pick returns the key that signs the next client update of the given CZ
+func (p *keyPool) pick(dstChainID string) string {
+	// NOTE: pinned is only written upon creation of the pool
+	if key, ok := p.pinned[dstChainID]; ok {
+		return key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.strategy == config.KeyStrategyLeastRecentlyUsed {
+		key := p.keys[0]
+		for _, k := range p.keys[1:] {
+			if p.lastUsed[k].Before(p.lastUsed[key]) {
+				key = k
+			}
+		}
+		p.lastUsed[key] = time.Now()
+		return key
+	}
+
+	// the round-robin strategy, which is also used for CZs without a pinned key
+	// in the pinned strategy
+	key := p.keys[p.next%len(p.keys)]
+	p.next = (p.next + 1) % len(p.keys)
+	return key
+}
+
+// UseSigners makes the client updates sent to the given Babylon chain signed
+// by the keys in its pool with its grants, rather than only by the key of the
+// chain. All keys have to exist in the keyring and hold the grants, if any.
+// It should be called before relaying starts.
+func (r *Relayer) UseSigners(
+	ctx context.Context,
+	babylonChain *relayer.Chain,
+	babylonChainCfg *config.BabylonChainConfig,
+) error {
+	p := newKeyPool(babylonChain, babylonChainCfg, r.cfg.Chains)
+	for _, key := range p.keys {
+		if !babylonChain.ChainProvider.KeyExists(key) {
+			return fmt.Errorf("key %s not found on Babylon chain %s", key, babylonChain.ChainID())
+		}
+	}
+	if _, err := CheckGrants(ctx, babylonChain, p.keys, babylonChainCfg.Grants); err != nil {
+		return err
+	}
+
+	r.keyPools[babylonChain.ChainID()] = p
+	r.grants[babylonChain.ChainID()] = babylonChainCfg.Grants
+	r.logger.Info(
+		"Signing client updates with key pool",
+		zap.String("src_chain_id", babylonChain.ChainID()),
+		zap.Strings("keys", p.keys),
+		zap.String("strategy", string(p.strategy)),
+		zap.String("fee_granter", babylonChainCfg.Grants.FeeGranter),
+		zap.String("authz_granter", babylonChainCfg.Grants.AuthzGranter),
+	)
+	return nil
+}
+
+// pickKey returns the key that signs the next client update of dst chain
+// sent to src chain, which is the key of src chain if src chain has no key pool
+func (r *Relayer) pickKey(src *relayer.Chain, dstChainID string) string {
+	if p, ok := r.keyPools[src.ChainID()]; ok {
+		return p.pick(dstChainID)
+	}
+	return src.ChainProvider.Key()
+}
+
+// pinnedKey returns the key that dst chain is pinned to on src chain, if any
+func (r *Relayer) pinnedKey(src *relayer.Chain, dstChainID string) (string, bool) {
+	p, ok := r.keyPools[src.ChainID()]
+	if !ok {
+		return "", false
+	}
+	key, ok := p.pinned[dstChainID]
+	return key, ok
+}
+
+// signingKeys returns all keys that sign client updates sent to the given chain
+func (r *Relayer) signingKeys(chain *relayer.Chain) []string {
+	if p, ok := r.keyPools[chain.ChainID()]; ok {
+		return p.keys
+	}
+	return []string{chain.ChainProvider.Key()}
+}
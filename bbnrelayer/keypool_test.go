@@ -0,0 +1,69 @@
+package bbnrelayer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+// newTestKeyPool creates the key pool of a Babylon chain relaying to the CZs
+// cz-0, cz-1 and cz-2, where cz-1 is pinned to key-2
+func newTestKeyPool(strategy config.KeyStrategy) *keyPool {
+	chains := relayer.Chains{}
+	babylonCfg := &config.BabylonChainConfig{
+		ChainName: "babylon",
+		Keys:      config.KeyPoolConfig{Names: []string{"key-0", "key-1", "key-2"}, Strategy: strategy},
+	}
+	for i := 0; i < 3; i++ {
+		chainName := fmt.Sprintf("cz-%d", i)
+		chains[chainName] = relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: chainName}, false)
+		babylonCfg.CZs = append(babylonCfg.CZs, &config.CZConfig{ChainName: chainName})
+	}
+	babylonCfg.CZs[1].Key = "key-2"
+	babylonChain := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testBabylonChainID}, false)
+	return newKeyPool(babylonChain, babylonCfg, chains)
+}
+
+func TestKeyPoolPick(t *testing.T) {
+	tests := []struct {
+		strategy    config.KeyStrategy
+		dstChainIDs []string
+		want        []string
+	}{
+		{
+			// the default strategy is round-robin
+			dstChainIDs: []string{"cz-0", "cz-0", "cz-2", "cz-0"},
+			want:        []string{"key-0", "key-1", "key-2", "key-0"},
+		},
+		{
+			// the pinned CZ does not take a turn
+			strategy:    config.KeyStrategyRoundRobin,
+			dstChainIDs: []string{"cz-0", "cz-1", "cz-2", "cz-1", "cz-0"},
+			want:        []string{"key-0", "key-2", "key-1", "key-2", "key-2"},
+		},
+		{
+			strategy:    config.KeyStrategyLeastRecentlyUsed,
+			dstChainIDs: []string{"cz-0", "cz-2", "cz-1", "cz-0", "cz-0"},
+			want:        []string{"key-0", "key-1", "key-2", "key-2", "key-0"},
+		},
+		{
+			// CZs without a key in their config are pinned to the keys in turn
+			strategy:    config.KeyStrategyPinned,
+			dstChainIDs: []string{"cz-0", "cz-1", "cz-2", "cz-2", "cz-0"},
+			want:        []string{"key-0", "key-2", "key-1", "key-1", "key-0"},
+		},
+	}
+	for _, tt := range tests {
+		p := newTestKeyPool(tt.strategy)
+		got := make([]string, 0, len(tt.dstChainIDs))
+		for _, dstChainID := range tt.dstChainIDs {
+			got = append(got, p.pick(dstChainID))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q: got keys %v for %v, expected %v", tt.strategy, got, tt.dstChainIDs, tt.want)
+		}
+	}
+}
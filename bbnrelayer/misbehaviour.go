@@ -0,0 +1,456 @@
+package bbnrelayer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/chains/cosmos"
+	"github.com/cosmos/relayer/v2/relayer/provider"
+	"go.uber.org/zap"
+)
+
+// consensusStateHeightsPageLimit is the number of consensus state heights of a
+// client queried per page
+const consensusStateHeightsPageLimit = 100
+
+// misbehaviourWatcher compares the consensus states of the client on src
+// chain that tracks dst chain against the headers served by witnesses, i.e.,
+// nodes of dst chain independent of the one the relayer uses
+type misbehaviourWatcher struct {
+	r   *Relayer
+	src *relayer.Chain
+	dst *relayer.Chain
+	cfg config.MisbehaviourConfig
+	// policy is the relay policy of dst chain, which applies to the
+	// submission of misbehaviour
+	policy config.RelayPolicy
+
+	witnesses []*cosmos.CosmosProvider
+	// clientID and checkedHeight are the client and the latest height whose
+	// consensus state has been found consistent with all witnesses, which is
+	// the trusted height from which the headers of a misbehaviour are verified
+	clientID      string
+	checkedHeight clienttypes.Height
+}
+
+// newMisbehaviourWatcher creates a misbehaviour watcher with a provider for
+// each witness, which shares the config of dst chain other than the RPC address
+func newMisbehaviourWatcher(
+	ctx context.Context,
+	r *Relayer,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	cfg config.MisbehaviourConfig,
+	policy config.RelayPolicy,
+) (*misbehaviourWatcher, error) {
+	dcc, err := cosmosProvider(dst)
+	if err != nil {
+		return nil, err
+	}
+	w := &misbehaviourWatcher{r: r, src: src, dst: dst, cfg: cfg, policy: policy}
+	for _, addr := range cfg.Witnesses {
+		pcfg := dcc.PCfg
+		pcfg.RPCAddr = addr
+		p, err := pcfg.NewProvider(r.logger, r.homePath, false, dcc.PCfg.ChainName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create provider of witness %s: %w", addr, err)
+		}
+		if err := p.Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to initialise provider of witness %s: %w", addr, err)
+		}
+		w.witnesses = append(w.witnesses, p.(*cosmos.CosmosProvider))
+	}
+	return w, nil
+}
+
+// run checks the client on every interval until ctx is done or the client is halted
+func (w *misbehaviourWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if w.check(ctx) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check compares the consensus states of the client relayed since the last
+// check against the headers of the witnesses, and returns true if the client
+// is halted, i.e., a conflicting header is found now or has been found before
+func (w *misbehaviourWatcher) check(ctx context.Context) bool {
+	logger := w.r.logger.With(
+		zap.String("src_chain_id", w.src.ChainID()),
+		zap.String("dst_chain_id", w.dst.ChainID()),
+	)
+
+	record, err := w.r.clientStore.Get(w.src.ChainID(), w.dst.ChainID())
+	if err != nil {
+		logger.Debug("no client to check for misbehaviour yet", zap.Error(err))
+		return false
+	}
+	if len(record.Halted) > 0 {
+		logger.Warn(
+			"CZ light client has been halted due to misbehaviour. Stop checking it for misbehaviour",
+			zap.String("dst_client_id", record.ClientID),
+			zap.String("reason", record.Halted),
+		)
+		return true
+	}
+	if record.ClientID != w.clientID {
+		w.clientID = record.ClientID
+		w.checkedHeight = clienttypes.ZeroHeight()
+		if record.MisbehaviourCheckedHeight != nil {
+			w.checkedHeight = *record.MisbehaviourCheckedHeight
+		}
+	}
+	logger = logger.With(zap.String("dst_client_id", w.clientID))
+
+	heights, err := w.uncheckedHeights(ctx)
+	if err != nil {
+		logger.Warn("failed to query consensus state heights to check for misbehaviour", zap.Error(err))
+		return false
+	}
+	checkedHeight := w.checkedHeight
+	for _, height := range heights {
+		consistent, halted := w.checkHeight(ctx, logger, height)
+		if halted {
+			return true
+		}
+		if !consistent {
+			// the height is checked again on the next interval
+			break
+		}
+		w.checkedHeight = height
+	}
+
+	// the checked height is kept in DB, so that the consensus states relayed
+	// while the relayer is down are checked upon restart
+	if w.checkedHeight.GT(checkedHeight) {
+		clientID, height := w.clientID, w.checkedHeight
+		if err := w.r.clientStore.Update(w.src.ChainID(), w.dst.ChainID(), func(record *ClientRecord) error {
+			if record.ClientID != clientID {
+				return errRecordUnchanged
+			}
+			record.MisbehaviourCheckedHeight = &height
+			return nil
+		}); err != nil && !errors.Is(err, errRecordUnchanged) {
+			logger.Warn("failed to record the height checked for misbehaviour in DB", zap.Error(err))
+		}
+	}
+	return false
+}
+
+// uncheckedHeights returns the heights of the consensus states of the client
+// after the last checked height in ascending order. Upon the first check of
+// the client, only the latest height is returned, since witnesses may have
+// pruned the headers of earlier heights.
+func (w *misbehaviourWatcher) uncheckedHeights(ctx context.Context) ([]clienttypes.Height, error) {
+	if w.checkedHeight.IsZero() {
+		clientState, err := w.src.ChainProvider.QueryClientState(ctx, 0, w.clientID)
+		if err != nil {
+			return nil, err
+		}
+		height, ok := clientState.GetLatestHeight().(clienttypes.Height)
+		if !ok {
+			return nil, fmt.Errorf("latest height of client %s is not an IBC height", w.clientID)
+		}
+		return []clienttypes.Height{height}, nil
+	}
+
+	cc, err := cosmosProvider(w.src)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		heights []clienttypes.Height
+		nextKey []byte
+	)
+	for {
+		res, err := clienttypes.NewQueryClient(cc).ConsensusStateHeights(ctx, &clienttypes.QueryConsensusStateHeightsRequest{
+			ClientId:   w.clientID,
+			Pagination: &query.PageRequest{Key: nextKey, Limit: consensusStateHeightsPageLimit},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, height := range res.ConsensusStateHeights {
+			if height.GT(w.checkedHeight) {
+				heights = append(heights, height)
+			}
+		}
+		if res.Pagination == nil || len(res.Pagination.NextKey) == 0 {
+			break
+		}
+		nextKey = res.Pagination.NextKey
+	}
+	// the heights are paginated in the order of their keys rather than numerically
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i].LT(heights[j])
+	})
+	return heights, nil
+}
+
+// checkHeight compares the consensus state of the client at the given height
+// against the headers of the witnesses. It returns whether the consensus state
+// is consistent with all witnesses, and whether the client is halted due to a
+// conflicting header.
+func (w *misbehaviourWatcher) checkHeight(ctx context.Context, logger *zap.Logger, height clienttypes.Height) (bool, bool) {
+	consensusState, err := w.queryConsensusState(ctx, height)
+	if err != nil {
+		logger.Warn("failed to query consensus state to check for misbehaviour", zap.Stringer("height", height), zap.Error(err))
+		return false, false
+	}
+
+	for i, witness := range w.witnesses {
+		header, err := witness.QueryIBCHeader(ctx, int64(height.RevisionHeight))
+		if err != nil {
+			// the witness may lag behind or be unavailable, so the height is checked again later
+			logger.Debug("failed to query header from witness", zap.String("witness", w.cfg.Witnesses[i]), zap.Stringer("height", height), zap.Error(err))
+			return false, false
+		}
+		witnessConsensusState, ok := header.ConsensusState().(*ibctm.ConsensusState)
+		if !ok {
+			logger.Warn("header from witness is not a Tendermint header", zap.String("witness", w.cfg.Witnesses[i]), zap.Stringer("height", height))
+			return false, false
+		}
+		if consensusStateMatches(consensusState, witnessConsensusState) {
+			continue
+		}
+
+		logger.Error(
+			"CZ light client holds a consensus state that conflicts with the header of a witness",
+			zap.Stringer("height", height),
+			zap.String("witness", w.cfg.Witnesses[i]),
+			zap.String("client_app_hash", hex.EncodeToString(consensusState.Root.GetHash())),
+			zap.String("witness_app_hash", hex.EncodeToString(witnessConsensusState.Root.GetHash())),
+			zap.Time("client_timestamp", consensusState.Timestamp),
+			zap.Time("witness_timestamp", witnessConsensusState.Timestamp),
+		)
+		w.r.haltClient(w.src.ChainID(), w.dst.ChainID(), newUpdateClientError(ErrClassMisbehaviour, fmt.Errorf(
+			"client %s of chain %s on chain %s conflicts with witness %s at height %s",
+			w.clientID, w.dst.ChainID(), w.src.ChainID(), w.cfg.Witnesses[i], height,
+		)))
+		w.r.metrics.MisbehavioursCounter.WithLabelValues(w.src.ChainID(), w.dst.ChainID(), "halted").Inc()
+		if w.cfg.HaltOnly {
+			return false, true
+		}
+
+		if err := w.submitMisbehaviour(ctx, height, witness, header); err != nil {
+			logger.Error("failed to submit misbehaviour, the client remains halted", zap.Stringer("height", height), zap.Error(err))
+			w.r.metrics.MisbehavioursCounter.WithLabelValues(w.src.ChainID(), w.dst.ChainID(), "submission_failed").Inc()
+			return false, true
+		}
+		logger.Info("Submitted misbehaviour, which freezes the client", zap.Stringer("height", height))
+		w.r.metrics.MisbehavioursCounter.WithLabelValues(w.src.ChainID(), w.dst.ChainID(), "submitted").Inc()
+		return false, true
+	}
+	return true, false
+}
+
+// queryConsensusState queries the Tendermint consensus state of the client at
+// the given height
+func (w *misbehaviourWatcher) queryConsensusState(ctx context.Context, height clienttypes.Height) (*ibctm.ConsensusState, error) {
+	res, err := w.src.ChainProvider.QueryClientConsensusState(ctx, 0, w.clientID, height)
+	if err != nil {
+		return nil, err
+	}
+	consensusState, err := clienttypes.UnpackConsensusState(res.ConsensusState)
+	if err != nil {
+		return nil, err
+	}
+	tmConsensusState, ok := consensusState.(*ibctm.ConsensusState)
+	if !ok {
+		return nil, fmt.Errorf("consensus state of client %s is not a Tendermint consensus state", w.clientID)
+	}
+	return tmConsensusState, nil
+}
+
+// submitMisbehaviour submits the misbehaviour consisting of the conflicting
+// header of the given witness and the header of the primary node of dst chain
+// at the given height, both verified from the last checked height of the client
+func (w *misbehaviourWatcher) submitMisbehaviour(
+	ctx context.Context,
+	height clienttypes.Height,
+	witness *cosmos.CosmosProvider,
+	witnessHeader provider.IBCHeader,
+) error {
+	trustedHeight := w.checkedHeight
+	if trustedHeight.IsZero() {
+		return fmt.Errorf("no consensus state of client %s has been checked before the conflicting height %s to verify the misbehaviour from", w.clientID, height)
+	}
+	dcc, err := cosmosProvider(w.dst)
+	if err != nil {
+		return err
+	}
+
+	witnessTMHeader, err := misbehaviourHeader(ctx, dcc, witness, witnessHeader, trustedHeight)
+	if err != nil {
+		return fmt.Errorf("failed to build header of witness: %w", err)
+	}
+	primaryHeader, err := dcc.QueryIBCHeader(ctx, int64(height.RevisionHeight))
+	if err != nil {
+		return fmt.Errorf("failed to query header at height %s: %w", height, err)
+	}
+	primaryConsensusState, ok := primaryHeader.ConsensusState().(*ibctm.ConsensusState)
+	if !ok {
+		return fmt.Errorf("header at height %s is not a Tendermint header", height)
+	}
+	witnessConsensusState, ok := witnessHeader.ConsensusState().(*ibctm.ConsensusState)
+	if !ok {
+		return fmt.Errorf("header of witness at height %s is not a Tendermint header", height)
+	}
+	// the misbehaviour is only valid with two different headers at the same height
+	if consensusStateMatches(primaryConsensusState, witnessConsensusState) {
+		return fmt.Errorf("the node of chain %s agrees with the witness at height %s, so no conflicting header can be obtained", w.dst.ChainID(), height)
+	}
+	primaryTMHeader, err := misbehaviourHeader(ctx, dcc, dcc, primaryHeader, trustedHeight)
+	if err != nil {
+		return fmt.Errorf("failed to build header of primary node: %w", err)
+	}
+
+	misbehaviour := ibctm.NewMisbehaviour(w.clientID, witnessTMHeader, primaryTMHeader)
+	msg, err := w.src.ChainProvider.MsgSubmitMisbehaviour(w.clientID, misbehaviour)
+	if err != nil {
+		return err
+	}
+
+	sendCtx, cancel := w.r.sendContext(ctx)
+	defer cancel()
+	_, err = w.r.sendMsgs(sendCtx, w.src, w.r.pickKey(w.src, w.dst.ChainID()), []string{w.dst.ChainID()}, []provider.RelayerMessage{msg}, w.policy)
+	return err
+}
+
+// misbehaviourHeader builds the Tendermint header of the given header of a
+// node of the CZ that can be verified from the given trusted height
+func misbehaviourHeader(
+	ctx context.Context,
+	dcc *cosmos.CosmosProvider,
+	node *cosmos.CosmosProvider,
+	header provider.IBCHeader,
+	trustedHeight clienttypes.Height,
+) (*ibctm.Header, error) {
+	// the trusted validators are the next validators at the trusted height
+	trustedHeader, err := node.QueryIBCHeader(ctx, int64(trustedHeight.RevisionHeight)+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trusted header at height %d: %w", trustedHeight.RevisionHeight+1, err)
+	}
+	clientMsg, err := dcc.MsgUpdateClientHeader(header, trustedHeight, trustedHeader)
+	if err != nil {
+		return nil, err
+	}
+	tmHeader, ok := clientMsg.(*ibctm.Header)
+	if !ok {
+		return nil, fmt.Errorf("header is not a Tendermint header")
+	}
+	return tmHeader, nil
+}
+
+// consensusStateMatches returns whether the consensus state stored in a
+// client matches the one derived from a header of the CZ
+func consensusStateMatches(stored *ibctm.ConsensusState, derived *ibctm.ConsensusState) bool {
+	return bytes.Equal(stored.Root.GetHash(), derived.Root.GetHash()) &&
+		bytes.Equal(stored.NextValidatorsHash, derived.NextValidatorsHash) &&
+		stored.Timestamp.Equal(derived.Timestamp)
+}
+
+// WatchMisbehaviour starts checking the client on src chain that tracks dst
+// chain for misbehaviour in a go routine added to wg, if witnesses are
+// configured for dst chain
+func (r *Relayer) WatchMisbehaviour(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	czCfg *config.CZConfig,
+) error {
+	cfg := czCfg.Misbehaviour
+	if len(cfg.Witnesses) == 0 {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("non-positive misbehaviour check interval %v", cfg.Interval)
+	}
+	w, err := newMisbehaviourWatcher(ctx, r, src, dst, cfg, czCfg.RelayPolicy)
+	if err != nil {
+		return err
+	}
+	r.logger.Info(
+		"Watching CZ light client for misbehaviour",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.Strings("witnesses", cfg.Witnesses),
+		zap.Duration("interval", cfg.Interval),
+		zap.Bool("halt_only", cfg.HaltOnly),
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.run(ctx)
+	}()
+	return nil
+}
+
+// haltClient halts the updates of the client on src chain that tracks dst
+// chain with the given error. The halt takes effect in memory at once, and is
+// recorded in DB so that it survives restarts.
+func (r *Relayer) haltClient(srcChainID string, dstChainID string, err error) {
+	r.haltedClientsMu.Lock()
+	r.haltedClients[chainPair{src: srcChainID, dst: dstChainID}] = err
+	r.haltedClientsMu.Unlock()
+
+	if recordErr := r.clientStore.Update(srcChainID, dstChainID, func(record *ClientRecord) error {
+		record.Halted = err.Error()
+		record.HaltedTime = time.Now().UTC()
+		return nil
+	}); recordErr != nil {
+		r.logger.Error(
+			"failed to record the halted client in DB, the client is only halted until restart",
+			zap.String("src_chain_id", srcChainID),
+			zap.String("dst_chain_id", dstChainID),
+			zap.Error(recordErr),
+		)
+	}
+}
+
+// haltedClientError returns the error with which the client on src chain that
+// tracks dst chain is halted, or nil if it is not halted
+func (r *Relayer) haltedClientError(srcChainID string, dstChainID string) error {
+	r.haltedClientsMu.RLock()
+	err := r.haltedClients[chainPair{src: srcChainID, dst: dstChainID}]
+	r.haltedClientsMu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	record, recordErr := r.clientStore.Get(srcChainID, dstChainID)
+	if recordErr != nil {
+		return nil
+	}
+	return haltedRecordError(record)
+}
+
+// haltedRecordError returns the error with which the client of the given
+// record is halted, or nil if it is not halted
+func haltedRecordError(record *ClientRecord) error {
+	if len(record.Halted) == 0 {
+		return nil
+	}
+	return newUpdateClientError(ErrClassMisbehaviour, errors.New(record.Halted))
+}
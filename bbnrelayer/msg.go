@@ -2,14 +2,15 @@ package bbnrelayer
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/avast/retry-go/v4"
+	"github.com/babylonchain/babylon-relayer/config"
 	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
 	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
 	"github.com/cosmos/relayer/v2/relayer"
 	"github.com/cosmos/relayer/v2/relayer/provider"
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
 // CreateMsgUpdateClient queries for the current client state on receiver,
@@ -18,66 +19,94 @@ import (
 // header and is sent to the `sender` IBC client with the given clientID
 // in the `receiver` chain.
 // same as https://github.com/cosmos/relayer/blob/v2.3.1/relayer/client.go
-// except for using the client ID from DB
+// except for using the client ID from DB, retrying requests according to the
+// given policy and returning classified errors
 func (r *Relayer) CreateMsgUpdateClient(
 	ctx context.Context,
 	sender, receiver *relayer.Chain,
 	senderHeight, receiverHeight int64,
 	clientID string,
+	policy config.RelayPolicy,
 ) (provider.RelayerMessage, error) {
 	var dstClientState ibcexported.ClientState
 	if err := retry.Do(func() error {
 		var err error
 		dstClientState, err = receiver.ChainProvider.QueryClientState(ctx, receiverHeight, clientID)
 		return err
-	}, retry.Context(ctx), relayer.RtyAtt, relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
 		r.logger.Info(
 			"Failed to query client state when updating clients",
 			zap.String("client_id", clientID),
 			zap.Uint("attempt", n+1),
-			zap.Uint("max_attempts", relayer.RtyAttNum),
+			zap.Uint("max_attempts", policy.Retries),
 			zap.Error(err),
 		)
 	})); err != nil {
-		return nil, err
+		return nil, wrapUpdateClientError(err)
 	}
 
-	var srcHeader, dstTrustedHeader provider.IBCHeader
+	// the node of the CZ reports the chain ID of the revision it serves in its
+	// headers, which moves to a new revision upon a chain-id upgrade (e.g.,
+	// osmo-test-4 -> osmo-test-5)
+	var srcHeader provider.IBCHeader
+	if err := retry.Do(func() error {
+		var err error
+		srcHeader, err = sender.ChainProvider.QueryIBCHeader(ctx, senderHeight)
+		return err
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+		r.logger.Info(
+			"Failed to query IBC header when building update client message",
+			zap.String("client_id", clientID),
+			zap.Uint("attempt", n+1),
+			zap.Uint("max_attempts", policy.Retries),
+			zap.Error(err),
+		)
+	})); err != nil {
+		return nil, wrapUpdateClientError(err)
+	}
+	chainID := headerChainID(srcHeader, sender.ChainID())
+	if chainID != sender.ChainID() {
+		if isPreviousRevision(sender.ChainID(), chainID) {
+			return nil, newUpdateClientError(ErrClassRevisionUpgrade, &revisionUpgradeError{chainID: sender.ChainID(), newChainID: chainID})
+		}
+		return nil, newUpdateClientError(ErrClassClientMismatch, fmt.Errorf(
+			"node of chain %s serves chain %s", sender.ChainID(), chainID,
+		))
+	}
 
-	eg, egCtx := errgroup.WithContext(ctx)
-	eg.Go(func() error {
-		return retry.Do(func() error {
-			var err error
-			srcHeader, err = sender.ChainProvider.QueryIBCHeader(egCtx, senderHeight)
-			return err
-		}, retry.Context(egCtx), relayer.RtyAtt, relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
-			r.logger.Info(
-				"Failed to query IBC header when building update client message",
-				zap.String("client_id", clientID),
-				zap.Uint("attempt", n+1),
-				zap.Uint("max_attempts", relayer.RtyAttNum),
-				zap.Error(err),
-			)
-		}))
-	})
-	eg.Go(func() error {
-		return retry.Do(func() error {
-			var err error
-			dstTrustedHeader, err = sender.ChainProvider.QueryIBCHeader(egCtx, int64(dstClientState.GetLatestHeight().GetRevisionHeight())+1)
-			return err
-		}, retry.Context(egCtx), relayer.RtyAtt, relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
-			r.logger.Info(
-				"Failed to query IBC header when building update client message",
-				zap.String("client_id", clientID),
-				zap.Uint("attempt", n+1),
-				zap.Uint("max_attempts", relayer.RtyAttNum),
-				zap.Error(err),
-			)
-		}))
-	})
+	// headers of the revision of the CZ cannot be verified by a client of
+	// another revision. As the node serves the configured chain ID, there is
+	// no new revision to follow, and the stored client has to be replaced.
+	trustedHeight := dstClientState.GetLatestHeight()
+	if revision := clienttypes.ParseChainID(chainID); revision != trustedHeight.GetRevisionNumber() {
+		return nil, newUpdateClientError(ErrClassClientMismatch, fmt.Errorf(
+			"chain %s has revision number %d while client %s has revision number %d",
+			chainID, revision, clientID, trustedHeight.GetRevisionNumber(),
+		))
+	}
+	// the CZ has not produced any new block since the last update
+	if uint64(senderHeight) <= trustedHeight.GetRevisionHeight() {
+		return nil, newUpdateClientError(ErrClassChainHalted, fmt.Errorf(
+			"latest height %d of chain %s is not higher than the latest height %d of client %s",
+			senderHeight, sender.ChainID(), trustedHeight.GetRevisionHeight(), clientID,
+		))
+	}
 
-	if err := eg.Wait(); err != nil {
-		return nil, err
+	var dstTrustedHeader provider.IBCHeader
+	if err := retry.Do(func() error {
+		var err error
+		dstTrustedHeader, err = sender.ChainProvider.QueryIBCHeader(ctx, int64(trustedHeight.GetRevisionHeight())+1)
+		return err
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+		r.logger.Info(
+			"Failed to query IBC header when building update client message",
+			zap.String("client_id", clientID),
+			zap.Uint("attempt", n+1),
+			zap.Uint("max_attempts", policy.Retries),
+			zap.Error(err),
+		)
+	})); err != nil {
+		return nil, wrapUpdateClientError(err)
 	}
 
 	var updateHeader ibcexported.ClientMessage
@@ -85,18 +114,31 @@ func (r *Relayer) CreateMsgUpdateClient(
 		var err error
 		updateHeader, err = sender.ChainProvider.MsgUpdateClientHeader(srcHeader, dstClientState.GetLatestHeight().(clienttypes.Height), dstTrustedHeader)
 		return err
-	}, retry.Context(ctx), relayer.RtyAtt, relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
 		r.logger.Info(
 			"Failed to build update client header",
 			zap.String("client_id", clientID),
 			zap.Uint("attempt", n+1),
-			zap.Uint("max_attempts", relayer.RtyAttNum),
+			zap.Uint("max_attempts", policy.Retries),
 			zap.Error(err),
 		)
 	})); err != nil {
-		return nil, err
+		return nil, newUpdateClientError(ErrClassHeaderVerification, err)
 	}
 
 	// updates off-chain light client
-	return receiver.ChainProvider.MsgUpdateClient(clientID, updateHeader)
+	msg, err := receiver.ChainProvider.MsgUpdateClient(clientID, updateHeader)
+	if err != nil {
+		return nil, wrapUpdateClientError(err)
+	}
+	return msg, nil
+}
+
+// headerChainID returns the chain ID in the given header, or the given default
+// if the header does not carry one
+func headerChainID(header provider.IBCHeader, defaultChainID string) string {
+	if tmHeader, ok := header.(provider.TendermintIBCHeader); ok && tmHeader.SignedHeader != nil && tmHeader.SignedHeader.Header != nil {
+		return tmHeader.SignedHeader.ChainID
+	}
+	return defaultChainID
 }
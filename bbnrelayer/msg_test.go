@@ -0,0 +1,32 @@
+package bbnrelayer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+func TestCreateMsgUpdateClientRevisionMismatch(t *testing.T) {
+	r := New("", nil, zap.NewNop(), relaydebug.NewPrometheusMetrics(), NewMemClientStore(), NewMemCostStore(), 0)
+	policy := config.RelayPolicy{Retries: 1, RetryDelay: time.Millisecond}
+	babylonChain := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testBabylonChainID, height: 100}, false)
+
+	// the client has revision number 0
+	czChain := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testCZChainID, height: 11}, false)
+	if _, err := r.CreateMsgUpdateClient(context.Background(), czChain, babylonChain, 11, 100, testClientID, policy); err != nil {
+		t.Errorf("got error %v for a client of the revision of the CZ, expected none", err)
+	}
+
+	// the node serves the configured chain ID, so the client of another
+	// revision cannot be upgraded and the relayer gives up on it
+	czChain = relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testCZChainID + "-2", height: 11}, false)
+	_, err := r.CreateMsgUpdateClient(context.Background(), czChain, babylonChain, 11, 100, testClientID, policy)
+	if got := ClassifyError(err); err == nil || got != ErrClassClientMismatch || !IsUnrecoverable(err) {
+		t.Errorf("got error %v of class %s for a client of another revision, expected an unrecoverable error of class %s", err, got, ErrClassClientMismatch)
+	}
+}
@@ -0,0 +1,108 @@
+package bbnrelayer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/chains/cosmos"
+)
+
+// ClientInfo is the on-chain information of a CZ light client on Babylon
+type ClientInfo struct {
+	ClientID string `json:"client_id"`
+	// Status is the status of the client, i.e., Active, Expired or Frozen
+	Status string `json:"status"`
+	// ChainID is the chain ID of the CZ tracked by the client
+	ChainID                  string             `json:"chain_id"`
+	LatestHeight             clienttypes.Height `json:"latest_height"`
+	FrozenHeight             clienttypes.Height `json:"frozen_height"`
+	TrustingPeriod           time.Duration      `json:"trusting_period"`
+	UnbondingPeriod          time.Duration      `json:"unbonding_period"`
+	MaxClockDrift            time.Duration      `json:"max_clock_drift"`
+	LatestConsensusTimestamp time.Time          `json:"latest_consensus_timestamp"`
+
+	TrustLevel string `json:"trust_level"`
+	// AllowUpdateAfterExpiry and AllowUpdateAfterMisbehaviour are the
+	// deprecated flags of the client
+	AllowUpdateAfterExpiry       bool `json:"allow_update_after_expiry"`
+	AllowUpdateAfterMisbehaviour bool `json:"allow_update_after_misbehaviour"`
+}
+
+// cosmosProvider returns the Cosmos provider of the given chain
+func cosmosProvider(chain *relayer.Chain) (*cosmos.CosmosProvider, error) {
+	cc, ok := chain.ChainProvider.(*cosmos.CosmosProvider)
+	if !ok {
+		return nil, fmt.Errorf("chain %s is not a Cosmos chain", chain.ChainID())
+	}
+	return cc, nil
+}
+
+// trustLevelString returns the given trust level in the form of numerator/denominator
+func trustLevelString(trustLevel ibctm.Fraction) string {
+	return fmt.Sprintf("%d/%d", trustLevel.Numerator, trustLevel.Denominator)
+}
+
+// QueryClientStatus queries the status of the given client on the given chain
+func QueryClientStatus(ctx context.Context, chain *relayer.Chain, clientID string) (ibcexported.Status, error) {
+	cc, err := cosmosProvider(chain)
+	if err != nil {
+		return ibcexported.Unknown, err
+	}
+	res, err := clienttypes.NewQueryClient(cc).ClientStatus(ctx, &clienttypes.QueryClientStatusRequest{ClientId: clientID})
+	if err != nil {
+		return ibcexported.Unknown, fmt.Errorf("failed to query status of client %s: %w", clientID, err)
+	}
+	return ibcexported.Status(res.Status), nil
+}
+
+// QueryClientInfo queries the latest client state, the latest consensus state
+// and the status of the given Tendermint client on the given chain
+func QueryClientInfo(ctx context.Context, chain *relayer.Chain, clientID string) (*ClientInfo, error) {
+	clientState, err := chain.ChainProvider.QueryClientState(ctx, 0, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client state of client %s: %w", clientID, err)
+	}
+	tmClientState, ok := clientState.(*ibctm.ClientState)
+	if !ok {
+		return nil, fmt.Errorf("client %s is not a Tendermint client", clientID)
+	}
+
+	consensusStateRes, err := chain.ChainProvider.QueryClientConsensusState(ctx, 0, clientID, tmClientState.LatestHeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consensus state of client %s at height %s: %w", clientID, tmClientState.LatestHeight, err)
+	}
+	consensusState, err := clienttypes.UnpackConsensusState(consensusStateRes.ConsensusState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack consensus state of client %s: %w", clientID, err)
+	}
+	tmConsensusState, ok := consensusState.(*ibctm.ConsensusState)
+	if !ok {
+		return nil, fmt.Errorf("consensus state of client %s is not a Tendermint consensus state", clientID)
+	}
+
+	status, err := QueryClientStatus(ctx, chain, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientInfo{
+		ClientID:                 clientID,
+		Status:                   status.String(),
+		ChainID:                  tmClientState.ChainId,
+		LatestHeight:             tmClientState.LatestHeight,
+		FrozenHeight:             tmClientState.FrozenHeight,
+		TrustingPeriod:           tmClientState.TrustingPeriod,
+		UnbondingPeriod:          tmClientState.UnbondingPeriod,
+		MaxClockDrift:            tmClientState.MaxClockDrift,
+		LatestConsensusTimestamp: tmConsensusState.Timestamp,
+
+		TrustLevel:                   trustLevelString(tmClientState.TrustLevel),
+		AllowUpdateAfterExpiry:       tmClientState.AllowUpdateAfterExpiry,       //nolint:staticcheck
+		AllowUpdateAfterMisbehaviour: tmClientState.AllowUpdateAfterMisbehaviour, //nolint:staticcheck
+	}, nil
+}
@@ -0,0 +1,88 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/provider"
+)
+
+// newClientRecord builds the record of a client that has just been created
+// on src chain at the given height
+func (r *Relayer) newClientRecord(
+	ctx context.Context,
+	src *relayer.Chain,
+	clientID string,
+	createdHeight int64,
+) (*ClientRecord, error) {
+	record := &ClientRecord{
+		ClientID:       clientID,
+		BabylonChainID: src.ChainID(),
+		CreatedHeight:  createdHeight,
+		CreatedTime:    time.Now().UTC(),
+	}
+
+	// the parameters of the client are decided upon creation,
+	// so query them from the client state
+	clientState, err := src.ChainProvider.QueryClientState(ctx, 0, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query client state of client %s: %w", clientID, err)
+	}
+	if tmClientState, ok := clientState.(*ibctm.ClientState); ok {
+		record.TrustingPeriod = tmClientState.TrustingPeriod
+		record.UnbondingPeriod = tmClientState.UnbondingPeriod
+		record.MaxClockDrift = tmClientState.MaxClockDrift
+		record.TrustLevel = trustLevelString(tmClientState.TrustLevel)
+		record.AllowUpdateAfterExpiry = tmClientState.AllowUpdateAfterExpiry             //nolint:staticcheck
+		record.AllowUpdateAfterMisbehaviour = tmClientState.AllowUpdateAfterMisbehaviour //nolint:staticcheck
+	}
+
+	return record, nil
+}
+
+// errRecordUnchanged is returned by the functions passed to ClientStore.Update
+// to skip writing records that are already up to date
+var errRecordUnchanged = errors.New("client record unchanged")
+
+// recordUpdateSuccess records the successfully relayed header of the given CZ on the given Babylon chain
+func (r *Relayer) recordUpdateSuccess(babylonChainID string, czChainID string, height int64, txResp *provider.RelayerTxResponse) error {
+	return r.clientStore.Update(babylonChainID, czChainID, func(record *ClientRecord) error {
+		record.LastRelayedHeight = height
+		record.LastUpdateTime = time.Now().UTC()
+		record.ConsecutiveFailures = 0
+		if txResp != nil {
+			record.LastUpdateTxHash = txResp.TxHash
+		}
+		return nil
+	})
+}
+
+// recordClientStatus records the status of the client of the given CZ on the given
+// Babylon chain, which is only written to DB upon changes
+func (r *Relayer) recordClientStatus(babylonChainID string, czChainID string, status ibcexported.Status) error {
+	err := r.clientStore.Update(babylonChainID, czChainID, func(record *ClientRecord) error {
+		if record.Status == status.String() {
+			return errRecordUnchanged
+		}
+		record.Status = status.String()
+		record.StatusTime = time.Now().UTC()
+		return nil
+	})
+	if errors.Is(err, errRecordUnchanged) {
+		return nil
+	}
+	return err
+}
+
+// recordUpdateFailure records a failed update of the client of the given CZ on the given Babylon chain
+func (r *Relayer) recordUpdateFailure(babylonChainID string, czChainID string) error {
+	return r.clientStore.Update(babylonChainID, czChainID, func(record *ClientRecord) error {
+		record.ConsecutiveFailures++
+		return nil
+	})
+}
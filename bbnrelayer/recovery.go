@@ -0,0 +1,227 @@
+package bbnrelayer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+// ClientSubstitution is a substitute client created for a client that has
+// expired or been frozen. Once a MsgRecoverClient pointing the subject client
+// at the substitute client is executed on Babylon, the subject client takes
+// over the state of the substitute client and becomes active again, so that
+// the timestamps of the CZ remain in the same client.
+type ClientSubstitution struct {
+	SubjectClientID    string `json:"subject_client_id"`
+	SubstituteClientID string `json:"substitute_client_id"`
+	// SubjectStatus is the status of the subject client upon substitution
+	SubjectStatus string `json:"subject_status"`
+	// SubjectCreatedHeight and SubjectCreatedTime are kept from the record of
+	// the subject client, so that they are restored upon recovery
+	SubjectCreatedHeight int64     `json:"subject_created_height"`
+	SubjectCreatedTime   time.Time `json:"subject_created_time"`
+	// CreatedTime is the time at which the substitute client is created
+	CreatedTime time.Time `json:"created_time"`
+	// RecoveredTime is the time at which the relayer finds the subject client
+	// recovered, which is zero until then
+	RecoveredTime time.Time `json:"recovered_time,omitempty"`
+}
+
+// RecoverClient creates a substitute client for the expired or frozen client
+// on src chain that tracks dst chain, and records the substitution in DB. The
+// relayer keeps the substitute client updated until the subject client is
+// recovered by a MsgRecoverClient, and then switches back to the subject client.
+func (r *Relayer) RecoverClient(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+	clientCfg config.ClientConfig,
+) (*ClientSubstitution, error) {
+	record, err := r.clientStore.Get(src.ChainID(), dst.ChainID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client of chain %s on chain %s: %w", dst.ChainID(), src.ChainID(), err)
+	}
+
+	// a client pending recovery is substituted again only if its current
+	// substitute is no longer active either
+	subjectClientID := record.ClientID
+	subjectCreatedHeight, subjectCreatedTime := record.CreatedHeight, record.CreatedTime
+	if len(record.SubjectClientID) > 0 {
+		status, err := QueryClientStatus(ctx, src, record.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if status == ibcexported.Active {
+			return nil, fmt.Errorf("client %s already substitutes client %s, which awaits a MsgRecoverClient", record.ClientID, record.SubjectClientID)
+		}
+		last := record.Substitutions[len(record.Substitutions)-1]
+		subjectClientID = record.SubjectClientID
+		subjectCreatedHeight, subjectCreatedTime = last.SubjectCreatedHeight, last.SubjectCreatedTime
+	}
+
+	subject, err := QueryClientInfo(ctx, src, subjectClientID)
+	if err != nil {
+		return nil, err
+	}
+	if subject.Status == ibcexported.Active.String() {
+		return nil, fmt.Errorf("client %s is active and need not be recovered", subjectClientID)
+	}
+
+	// MsgRecoverClient requires the substitute client to have the same
+	// parameters as the subject client other than the trusting period
+	clientCfg.UnbondingPeriod = subject.UnbondingPeriod
+	clientCfg.MaxClockDrift = subject.MaxClockDrift
+	clientCfg.TrustLevel = subject.TrustLevel
+	clientCfg.AllowUpdateAfterExpiry = &subject.AllowUpdateAfterExpiry
+	clientCfg.AllowUpdateAfterMisbehaviour = &subject.AllowUpdateAfterMisbehaviour
+	// the substitute client must be new rather than any client matching it
+	override := true
+	clientCfg.Override = &override
+
+	srch, dsth, err := r.queryCommittedHeights(ctx, src, dst, policy)
+	if err != nil {
+		return nil, err
+	}
+	substitute, err := r.createNewClient(ctx, src, dst, srch, dsth, policy, clientCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	substitution := ClientSubstitution{
+		SubjectClientID:      subjectClientID,
+		SubstituteClientID:   substitute.ClientID,
+		SubjectStatus:        subject.Status,
+		SubjectCreatedHeight: subjectCreatedHeight,
+		SubjectCreatedTime:   subjectCreatedTime,
+		CreatedTime:          substitute.CreatedTime,
+	}
+	substitute.SubjectClientID = subjectClientID
+	substitute.Substitutions = append(record.Substitutions, substitution)
+	if err := r.clientStore.Set(src.ChainID(), dst.ChainID(), substitute); err != nil {
+		return nil, fmt.Errorf("error writing substitute client %s for chain %s to DB: %w", substitute.ClientID, dst.ChainID(), err)
+	}
+
+	r.logger.Info(
+		"Created substitute client, which is updated until the subject client is recovered by a MsgRecoverClient",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("subject_client_id", subjectClientID),
+		zap.String("subject_status", subject.Status),
+		zap.String("substitute_client_id", substitute.ClientID),
+	)
+	return &substitution, nil
+}
+
+// checkRecovery switches the given record of the client on src chain that
+// tracks dst chain back to the subject client of its substitute once the
+// subject client has been recovered, and returns the up-to-date record
+func (r *Relayer) checkRecovery(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	record *ClientRecord,
+) (*ClientRecord, error) {
+	if len(record.SubjectClientID) == 0 || len(record.Substitutions) == 0 {
+		return record, nil
+	}
+	// the substitute client is kept updated until then
+	status, err := QueryClientStatus(ctx, src, record.SubjectClientID)
+	if err != nil || status != ibcexported.Active {
+		return record, nil
+	}
+
+	substitutions := append([]ClientSubstitution{}, record.Substitutions...)
+	last := &substitutions[len(substitutions)-1]
+	recovered, err := r.newClientRecord(ctx, src, record.SubjectClientID, last.SubjectCreatedHeight)
+	if err != nil {
+		return nil, err
+	}
+	last.RecoveredTime = time.Now().UTC()
+	recovered.CreatedTime = last.SubjectCreatedTime
+	recovered.LastRelayedHeight = record.LastRelayedHeight
+	recovered.LastUpdateTxHash = record.LastUpdateTxHash
+	recovered.LastUpdateTime = record.LastUpdateTime
+	recovered.Substitutions = substitutions
+	if err := r.clientStore.Set(src.ChainID(), dst.ChainID(), recovered); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info(
+		"Subject client has been recovered from its substitute. Resume updating the subject client",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("subject_client_id", last.SubjectClientID),
+		zap.String("substitute_client_id", last.SubstituteClientID),
+	)
+	return recovered, nil
+}
+
+// govProposal is the proposal file of `tx gov submit-proposal` of Cosmos SDK
+type govProposal struct {
+	Messages  []json.RawMessage `json:"messages"`
+	Metadata  string            `json:"metadata"`
+	Deposit   string            `json:"deposit"`
+	Title     string            `json:"title"`
+	Summary   string            `json:"summary"`
+	Expedited bool              `json:"expedited,omitempty"`
+}
+
+// RecoverClientProposal returns the governance proposal on the given chain that
+// recovers the subject client of the given substitution from its substitute
+// client, in the format of `tx gov submit-proposal`. The authority defaults to
+// the gov module account.
+func RecoverClientProposal(
+	chain *relayer.Chain,
+	substitution *ClientSubstitution,
+	authority string,
+	deposit string,
+	title string,
+	summary string,
+	expedited bool,
+) ([]byte, error) {
+	cc, err := cosmosProvider(chain)
+	if err != nil {
+		return nil, err
+	}
+	if len(authority) == 0 {
+		if authority, err = cc.EncodeBech32AccAddr(authtypes.NewModuleAddress(govtypes.ModuleName)); err != nil {
+			return nil, err
+		}
+	}
+	if len(title) == 0 {
+		title = fmt.Sprintf("Recover IBC client %s", substitution.SubjectClientID)
+	}
+	if len(summary) == 0 {
+		summary = fmt.Sprintf(
+			"Recover the %s IBC client %s with the state of the active substitute client %s.",
+			substitution.SubjectStatus, substitution.SubjectClientID, substitution.SubstituteClientID,
+		)
+	}
+
+	msg := &clienttypes.MsgRecoverClient{
+		SubjectClientId:    substitution.SubjectClientID,
+		SubstituteClientId: substitution.SubstituteClientID,
+		Signer:             authority,
+	}
+	msgBz, err := cc.Cdc.Marshaler.MarshalInterfaceJSON(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal MsgRecoverClient: %w", err)
+	}
+	return json.MarshalIndent(govProposal{
+		Messages:  []json.RawMessage{msgBz},
+		Deposit:   deposit,
+		Title:     title,
+		Summary:   summary,
+		Expedited: expedited,
+	}, "", "  ")
+}
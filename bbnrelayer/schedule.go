@@ -0,0 +1,229 @@
+package bbnrelayer
+
+import (
+	"context"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+const (
+	// minUpdateDelay is the minimum delay between two updates of a client, so that
+	// the relayer does not hammer Babylon when the trust window is about to close
+	minUpdateDelay = 10 * time.Second
+	// maxFailureSpeedupShift bounds how much faster updates are scheduled after
+	// consecutive failures, i.e., at most 2^maxFailureSpeedupShift times faster
+	maxFailureSpeedupShift = 4
+)
+
+// scheduleDelay returns the delay before the next update of a client whose
+// latest consensus state has the given timestamp. The delay is at most the
+// interval of the policy, and at most the trust fraction of the policy of the
+// remaining trust window. It is shortened further after consecutive failures.
+func scheduleDelay(
+	policy config.RelayPolicy,
+	trustingPeriod time.Duration,
+	latestConsensusTimestamp time.Time,
+	consecutiveFailures uint,
+	now time.Time,
+) time.Duration {
+	delay := policy.Interval
+	if trustingPeriod > 0 && policy.TrustFraction > 0 {
+		remaining := latestConsensusTimestamp.Add(trustingPeriod).Sub(now)
+		if bound := time.Duration(float64(remaining) * policy.TrustFraction); bound < delay {
+			delay = bound
+		}
+	}
+	if consecutiveFailures > 0 {
+		delay >>= min(consecutiveFailures, maxFailureSpeedupShift)
+	}
+	if floor := min(minUpdateDelay, policy.Interval); delay < floor {
+		delay = floor
+	}
+	return delay
+}
+
+// nextUpdateDelay returns the delay before the next update of the client on
+// src chain that tracks dst chain. If the trust window of the client cannot
+// be queried, the interval of the policy is used.
+func (r *Relayer) nextUpdateDelay(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+) time.Duration {
+	record, err := r.clientStore.Get(src.ChainID(), dst.ChainID())
+	if err != nil {
+		r.logger.Warn(
+			"failed to get client ID for scheduling the next update, use the interval",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.Error(err),
+		)
+		return policy.Interval
+	}
+	info, err := QueryClientInfo(ctx, src, record.ClientID)
+	if err != nil {
+		r.logger.Warn(
+			"failed to query trust window of the client for scheduling the next update, use the interval",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.String("dst_client_id", record.ClientID),
+			zap.Error(err),
+		)
+		return scheduleDelay(policy, 0, time.Time{}, record.ConsecutiveFailures, time.Now())
+	}
+	r.supervisor.recordClientInfo(chainPair{src: src.ChainID(), dst: dst.ChainID()}, record, info, time.Now())
+
+	delay := scheduleDelay(policy, info.TrustingPeriod, info.LatestConsensusTimestamp, record.ConsecutiveFailures, time.Now())
+	r.logger.Debug(
+		"scheduled the next update",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("dst_client_id", record.ClientID),
+		zap.Time("latest_consensus_timestamp", info.LatestConsensusTimestamp),
+		zap.Duration("trusting_period", info.TrustingPeriod),
+		zap.Uint("consecutive_failures", record.ConsecutiveFailures),
+		zap.Duration("delay", delay),
+	)
+	return delay
+}
+
+// checkTrustWindow warns if the interval of the policy cannot keep the client
+// on src chain that tracks dst chain within its trust window, in which case
+// updates are scheduled by the trust window rather than the interval
+func (r *Relayer) checkTrustWindow(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+) {
+	record, err := r.clientStore.Get(src.ChainID(), dst.ChainID())
+	if err != nil {
+		return
+	}
+	info, err := QueryClientInfo(ctx, src, record.ClientID)
+	if err != nil || policy.TrustFraction <= 0 {
+		return
+	}
+	if maxInterval := time.Duration(float64(info.TrustingPeriod) * policy.TrustFraction); policy.Interval > maxInterval {
+		r.logger.Warn(
+			"the configured interval cannot satisfy the trust window of the client. Updates will be scheduled by the trust window instead, consider decreasing the interval",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.String("dst_client_id", record.ClientID),
+			zap.Duration("interval", policy.Interval),
+			zap.Duration("trusting_period", info.TrustingPeriod),
+			zap.Float64("trust_fraction", policy.TrustFraction),
+			zap.Duration("max_interval", maxInterval),
+		)
+	}
+}
+
+// keepUpdatingClientOnInterval keeps updating the client on src chain that
+// tracks dst chain, where the next update is scheduled adaptively according
+// to the trust window of the client and the failures so far, bounded by the
+// interval of the policy
+func (r *Relayer) keepUpdatingClientOnInterval(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info(
+				"Stop updating client upon shutdown",
+				zap.String("src_chain_id", src.ChainID()),
+				zap.String("dst_chain_id", dst.ChainID()),
+			)
+			return nil
+		case <-timer.C:
+		}
+
+		if err := r.updateClientAndHandleError(ctx, src, dst, policy); err != nil {
+			return err
+		}
+		delay := r.nextUpdateDelay(ctx, src, dst, policy)
+		r.supervisor.setNextUpdate(chainPair{src: src.ChainID(), dst: dst.ChainID()}, time.Now().Add(delay))
+		timer.Reset(delay)
+	}
+}
+
+// keepUpdatingClientOnBlocks keeps updating the client on src chain that
+// tracks dst chain, where the client is updated once dst chain has advanced
+// the block delta of the policy since the last update, or once the delay
+// scheduled as in the interval mode has passed, whichever comes first
+func (r *Relayer) keepUpdatingClientOnBlocks(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+) error {
+	// the height of the last relayed header is the base of the block delta
+	var lastHeight int64
+	if record, err := r.clientStore.Get(src.ChainID(), dst.ChainID()); err == nil {
+		lastHeight = record.LastRelayedHeight
+	}
+	// update the client right away, as in the interval mode
+	deadline := time.Now()
+
+	ticker := time.NewTicker(policy.PollInterval)
+	defer ticker.Stop()
+	for {
+		// poll the latest height of dst chain, where a failed query only
+		// leaves the update to the deadline
+		dsth, err := dst.ChainProvider.QueryLatestHeight(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Debug(
+				"Failed to query latest height of CZ",
+				zap.String("src_chain_id", src.ChainID()),
+				zap.String("dst_chain_id", dst.ChainID()),
+				zap.Error(err),
+			)
+		}
+		blocksDue := err == nil && dsth-lastHeight >= int64(policy.BlockDelta)
+		timeDue := !time.Now().Before(deadline)
+
+		if blocksDue || timeDue {
+			r.logger.Debug(
+				"Update client in the blocks mode",
+				zap.String("src_chain_id", src.ChainID()),
+				zap.String("dst_chain_id", dst.ChainID()),
+				zap.Int64("last_height", lastHeight),
+				zap.Int64("latest_height", dsth),
+				zap.Bool("blocks_due", blocksDue),
+				zap.Bool("time_due", timeDue),
+			)
+			if err := r.updateClientAndHandleError(ctx, src, dst, policy); err != nil {
+				return err
+			}
+			// NOTE: the base moves forward even if the update failed,
+			// so that failures do not trigger an update upon every poll
+			if blocksDue {
+				lastHeight = dsth
+			}
+			if record, err := r.clientStore.Get(src.ChainID(), dst.ChainID()); err == nil && record.LastRelayedHeight > lastHeight {
+				lastHeight = record.LastRelayedHeight
+			}
+			deadline = time.Now().Add(r.nextUpdateDelay(ctx, src, dst, policy))
+			r.supervisor.setNextUpdate(chainPair{src: src.ChainID(), dst: dst.ChainID()}, deadline)
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info(
+				"Stop updating client upon shutdown",
+				zap.String("src_chain_id", src.ChainID()),
+				zap.String("dst_chain_id", dst.ChainID()),
+			)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package bbnrelayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+)
+
+func TestScheduleDelay(t *testing.T) {
+	now := time.Now()
+	policy := config.RelayPolicy{Interval: 10 * time.Minute, TrustFraction: 0.5}
+	tests := []struct {
+		name                string
+		policy              config.RelayPolicy
+		trustingPeriod      time.Duration
+		latestConsensusTime time.Time
+		consecutiveFailures uint
+		want                time.Duration
+	}{
+		{
+			name:   "unknown trust window",
+			policy: policy,
+			want:   10 * time.Minute,
+		},
+		{
+			name:                "trust window longer than the interval",
+			policy:              policy,
+			trustingPeriod:      time.Hour,
+			latestConsensusTime: now,
+			want:                10 * time.Minute,
+		},
+		{
+			name:                "trust window shorter than the interval",
+			policy:              policy,
+			trustingPeriod:      time.Hour,
+			latestConsensusTime: now.Add(-50 * time.Minute),
+			want:                5 * time.Minute,
+		},
+		{
+			name:                "no trust fraction",
+			policy:              config.RelayPolicy{Interval: 10 * time.Minute},
+			trustingPeriod:      time.Hour,
+			latestConsensusTime: now.Add(-50 * time.Minute),
+			want:                10 * time.Minute,
+		},
+		{
+			name:                "expired client",
+			policy:              policy,
+			trustingPeriod:      time.Hour,
+			latestConsensusTime: now.Add(-2 * time.Hour),
+			want:                minUpdateDelay,
+		},
+		{
+			name:                "consecutive failures",
+			policy:              policy,
+			consecutiveFailures: 2,
+			want:                10 * time.Minute / 4,
+		},
+		{
+			name:                "speedup is bounded",
+			policy:              policy,
+			consecutiveFailures: 10,
+			want:                10 * time.Minute >> maxFailureSpeedupShift,
+		},
+		{
+			name:                "delay is at least the minimum delay",
+			policy:              config.RelayPolicy{Interval: time.Minute},
+			consecutiveFailures: 4,
+			want:                minUpdateDelay,
+		},
+		{
+			name:   "interval shorter than the minimum delay",
+			policy: config.RelayPolicy{Interval: time.Second},
+			want:   time.Second,
+		},
+	}
+	for _, tt := range tests {
+		got := scheduleDelay(tt.policy, tt.trustingPeriod, tt.latestConsensusTime, tt.consecutiveFailures, now)
+		if got != tt.want {
+			t.Errorf("%s: scheduleDelay() = %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	errorsmod "cosmossdk.io/errors"
+	"github.com/avast/retry-go/v4"
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	txtypes "github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/chains/cosmos"
+	"github.com/cosmos/relayer/v2/relayer/provider"
+	"go.uber.org/zap"
+)
+
+const (
+	// txInclusionTimeout is the maximum time of waiting for a broadcast tx to be
+	// included in a block (same as the default of the Cosmos provider)
+	txInclusionTimeout = 10 * time.Minute
+	// txInclusionPollInterval is the interval of polling whether a broadcast tx
+	// has been included in a block
+	txInclusionPollInterval = 500 * time.Millisecond
+)
+
+// accountSeqRegex parses the error of an account sequence mismatch, e.g.,
+// "account sequence mismatch, expected 10, got 9: incorrect account sequence"
+var accountSeqRegex = regexp.MustCompile(`account sequence mismatch, expected ([0-9]+), got ([0-9]+)`)
+
+// maxGasRegex parses the error of the Cosmos provider upon a simulated gas
+// higher than its max gas amount, e.g.,
+// "estimated gas 500000 is higher than max gas 400000"
+var maxGasRegex = regexp.MustCompile(`estimated gas ([0-9]+) is higher than max gas ([0-9]+)`)
+
+// gasLimitError means that a tx needs more gas than a tx can use, so the tx
+// is not broadcast, and its msgs have to be sent in smaller txs
+type gasLimitError struct {
+	gas   uint64
+	limit uint64
+}
+
+func (e *gasLimitError) Error() string {
+	return fmt.Sprintf("tx needs %d gas, more than the gas limit %d", e.gas, e.limit)
+}
+
+// asGasLimitError converts the error of the Cosmos provider upon exceeding its
+// max gas amount into a gasLimitError, and returns other errors as they are
+func asGasLimitError(err error) error {
+	matches := maxGasRegex.FindStringSubmatch(err.Error())
+	if len(matches) == 0 {
+		return err
+	}
+	gas, gasErr := strconv.ParseUint(matches[1], 10, 64)
+	limit, limitErr := strconv.ParseUint(matches[2], 10, 64)
+	if gasErr != nil || limitErr != nil {
+		return err
+	}
+	return &gasLimitError{gas: gas, limit: limit}
+}
+
+// sequenceManager assigns account sequences to the txs signed by a key on a
+// Babylon chain. It tracks the next sequence of the key in memory, so that a
+// tx only holds the key while being built and broadcast rather than until it
+// is included in a block, and multiple txs signed by the key can be in flight
+// at once.
+type sequenceManager struct {
+	chainID string
+	key     string
+
+	mu sync.Mutex
+	// nextSequence is the sequence of the next tx, which is ahead of the
+	// sequence on chain while txs are in flight. 0 means that the sequence
+	// on chain is used.
+	nextSequence uint64
+	// gasPerMsg is the gas per msg of the last simulated tx, which estimates
+	// the gas of txs that cannot be simulated. 0 means no estimate.
+	gasPerMsg uint64
+}
+
+func newSequenceManager(chainID string, key string) *sequenceManager {
+	return &sequenceManager{
+		chainID: chainID,
+		key:     key,
+	}
+}
+
+// broadcast builds a tx with the given msgs using the next sequence of the key,
+// broadcasts it to the mempool of the given chain and returns its hash and
+// fees. Upon an account sequence mismatch, the next sequence is resynced so
+// that the next attempt uses the sequence expected by the chain.
+func (m *sequenceManager) broadcast(
+	ctx context.Context,
+	cc *cosmos.CosmosProvider,
+	msgs []provider.RelayerMessage,
+	memo string,
+	grants config.GrantsConfig,
+) (string, sdk.Coins, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var txHash string
+	txBytes, sequence, fees, err := m.buildTx(ctx, cc, msgs, memo, grants)
+	if err == nil {
+		txHash, err = broadcastTxSync(ctx, cc, txBytes)
+	}
+	if err != nil {
+		// the mismatch can also happen when simulating the tx
+		if ClassifyError(err) == ErrClassSequenceMismatch {
+			m.resyncLocked(err)
+		}
+		return "", nil, err
+	}
+
+	m.nextSequence = sequence + 1
+	return txHash, fees, nil
+}
+
+// buildTx builds and signs a tx with the given msgs and grants, and returns
+// the tx bytes, the sequence used for signing and the fees
+func (m *sequenceManager) buildTx(
+	ctx context.Context,
+	cc *cosmos.CosmosProvider,
+	msgs []provider.RelayerMessage,
+	memo string,
+	grants config.GrantsConfig,
+) ([]byte, uint64, sdk.Coins, error) {
+	done := cc.SetSDKContext()
+	defer done()
+
+	// the msgs are signed by the key of this manager rather than the key of
+	// the chain, or by the authz granter on behalf of which the key executes them
+	signer, err := cc.GetKeyAddressForKey(m.key)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	msgSigner, err := cc.EncodeBech32AccAddr(signer)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if len(grants.AuthzGranter) > 0 {
+		msgSigner = grants.AuthzGranter
+	}
+	for _, msg := range msgs {
+		if cMsg, ok := msg.(cosmos.CosmosMessage); ok && cMsg.SetSigner != nil {
+			cMsg.SetSigner(msgSigner)
+		}
+	}
+	cMsgs := cosmos.CosmosMsgs(msgs...)
+	if len(grants.AuthzGranter) > 0 {
+		msgExec := authz.NewMsgExec(signer, cMsgs)
+		cMsgs = []sdk.Msg{&msgExec}
+	}
+
+	// the factory is prepared with the account number and sequence on chain
+	txf, err := cc.PrepareFactory(cc.TxFactory(), m.key)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if memo != "" {
+		txf = txf.WithMemo(memo)
+	}
+	if len(grants.FeeGranter) > 0 {
+		feeGranter, err := sdk.GetFromBech32(grants.FeeGranter, cc.PCfg.AccountPrefix)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("invalid fee granter %s: %w", grants.FeeGranter, err)
+		}
+		txf = txf.WithFeeGranter(feeGranter)
+	}
+
+	// in-flight txs have used the sequences ahead of the one on chain
+	committedSequence := txf.Sequence()
+	sequence := committedSequence
+	if m.nextSequence > sequence {
+		sequence = m.nextSequence
+		txf = txf.WithSequence(sequence)
+	}
+
+	gas, err := m.estimateGasLocked(sequence, committedSequence, len(msgs), func() (uint64, error) {
+		_, gas, err := cc.CalculateGas(ctx, txf, m.key, cMsgs...)
+		return gas, err
+	})
+	if err != nil {
+		return nil, 0, nil, asGasLimitError(err)
+	}
+	// a tx over the max gas of a block is never included, so it is not
+	// broadcast and its msgs are sent in smaller txs instead
+	blockMaxGas, err := queryBlockMaxGas(ctx, cc)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if blockMaxGas > 0 && gas > blockMaxGas {
+		return nil, 0, nil, &gasLimitError{gas: gas, limit: blockMaxGas}
+	}
+	txf = txf.WithGas(gas)
+
+	txb, err := txf.BuildUnsignedTx(cMsgs...)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if err := tx.Sign(ctx, txf, m.key, txb, false); err != nil {
+		return nil, 0, nil, err
+	}
+	txBytes, err := cc.Cdc.TxConfig.TxEncoder()(txb.GetTx())
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return txBytes, sequence, txb.GetTx().GetFee(), nil
+}
+
+// estimateGasLocked returns the gas of a tx with the given number of msgs and
+// the given sequence, where committedSequence is the sequence of the key in
+// the committed state. Nodes may simulate txs against the committed state
+// rather than their mempool, in which case a tx whose sequence is ahead of the
+// committed one fails the simulation with an account sequence mismatch. The
+// gas of such a tx is estimated from the last simulated tx instead, if any.
+// Otherwise, the tx is simulated by the given func. m.mu must be held.
+func (m *sequenceManager) estimateGasLocked(
+	sequence uint64,
+	committedSequence uint64,
+	numMsgs int,
+	simulate func() (uint64, error),
+) (uint64, error) {
+	if sequence > committedSequence && m.gasPerMsg > 0 {
+		return m.gasPerMsg * uint64(numMsgs), nil
+	}
+	gas, err := simulate()
+	if err != nil {
+		return 0, err
+	}
+	if numMsgs > 0 {
+		m.gasPerMsg = (gas + uint64(numMsgs) - 1) / uint64(numMsgs)
+	}
+	return gas, nil
+}
+
+// queryBlockMaxGas queries the max gas of a block on the given chain, where 0
+// means unlimited
+func queryBlockMaxGas(ctx context.Context, cc *cosmos.CosmosProvider) (uint64, error) {
+	res, err := cc.RPCClient.ConsensusParams(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query consensus params: %w", err)
+	}
+	if res.ConsensusParams.Block.MaxGas <= 0 {
+		return 0, nil
+	}
+	return uint64(res.ConsensusParams.Block.MaxGas), nil
+}
+
+// resyncLocked resyncs the next sequence after an account sequence mismatch.
+// The sequence expected by the chain is used if it is in the error, otherwise
+// the sequence on chain is used for the next tx. m.mu must be held.
+func (m *sequenceManager) resyncLocked(err error) {
+	m.nextSequence = 0
+	matches := accountSeqRegex.FindStringSubmatch(err.Error())
+	if len(matches) == 0 {
+		return
+	}
+	if expected, parseErr := strconv.ParseUint(matches[1], 10, 64); parseErr == nil {
+		m.nextSequence = expected
+	}
+}
+
+// reset makes the next tx use the given sequence queried from chain
+func (m *sequenceManager) reset(sequence uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextSequence = sequence
+}
+
+// broadcastTxSync broadcasts the given tx and returns its hash, or an error
+// if the tx is not accepted to the mempool
+func broadcastTxSync(ctx context.Context, cc *cosmos.CosmosProvider, txBytes []byte) (string, error) {
+	res, err := cc.RPCClient.BroadcastTxSync(ctx, txBytes)
+	if err != nil {
+		return "", err
+	}
+	if res.Code != 0 {
+		return "", txExecutionError(res.Codespace, res.Code, res.Log)
+	}
+	return res.Hash.String(), nil
+}
+
+// txExecutionError returns the error of a tx that failed with the given code,
+// which carries the message of the registered error, if any, so that it can
+// be classified
+func txExecutionError(codespace string, code uint32, log string) error {
+	return errorsmod.ABCIError(codespace, code, fmt.Sprintf("transaction failed to execute: codespace: %s, code: %d, log: %s", codespace, code, log))
+}
+
+// waitForTx waits until the tx with the given hash is included in a block of
+// the given chain, and returns its response, which is also returned together
+// with an error if the tx failed
+func waitForTx(ctx context.Context, cc *cosmos.CosmosProvider, txHash string) (*sdk.TxResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, txInclusionTimeout)
+	defer cancel()
+
+	txClient := txtypes.NewServiceClient(cc)
+	ticker := time.NewTicker(txInclusionPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to wait for tx %s to be included: %w", txHash, ctx.Err())
+		case <-ticker.C:
+		}
+
+		res, err := txClient.GetTx(ctx, &txtypes.GetTxRequest{Hash: txHash})
+		if err != nil || res.TxResponse == nil {
+			// the tx is not included yet
+			continue
+		}
+		if res.TxResponse.Code != 0 {
+			return res.TxResponse, txExecutionError(res.TxResponse.Codespace, res.TxResponse.Code, res.TxResponse.RawLog)
+		}
+		return res.TxResponse, nil
+	}
+}
+
+// newRelayerTxResponse converts the given tx response into the one of the relayer
+func newRelayerTxResponse(res *sdk.TxResponse) *provider.RelayerTxResponse {
+	events := make([]provider.RelayerEvent, 0, len(res.Events))
+	for _, event := range res.Events {
+		attributes := make(map[string]string, len(event.Attributes))
+		for _, attribute := range event.Attributes {
+			attributes[attribute.Key] = attribute.Value
+		}
+		events = append(events, provider.RelayerEvent{
+			EventType:  event.Type,
+			Attributes: attributes,
+		})
+	}
+	return &provider.RelayerTxResponse{
+		Height:    res.Height,
+		TxHash:    res.TxHash,
+		Codespace: res.Codespace,
+		Code:      res.Code,
+		Data:      res.Data,
+		Events:    events,
+	}
+}
+
+// sequenceManager returns the sequence manager of the given key on the given
+// chain, and creates it if it does not exist yet
+func (r *Relayer) sequenceManager(chainID string, key string) *sequenceManager {
+	r.sequenceManagersMu.Lock()
+	defer r.sequenceManagersMu.Unlock()
+
+	id := chainID + "/" + key
+	m, ok := r.sequenceManagers[id]
+	if !ok {
+		m = newSequenceManager(chainID, key)
+		r.sequenceManagers[id] = m
+	}
+	return m
+}
+
+// sendMsgs sends the given msgs to src chain in a single tx signed by the given
+// key with the grants of src chain, and waits for the tx to be included. The
+// costs of the tx are attributed to dst chains, where dstChainIDs[i] is the
+// chain ID of the CZ of msgs[i].
+func (r *Relayer) sendMsgs(
+	ctx context.Context,
+	src *relayer.Chain,
+	key string,
+	dstChainIDs []string,
+	msgs []provider.RelayerMessage,
+	policy config.RelayPolicy,
+) (*provider.RelayerTxResponse, error) {
+	res, fees, err := r.broadcastTx(ctx, src, key, msgs, policy)
+	if res == nil {
+		return nil, err
+	}
+	// the fees are paid once the tx is included, even if it failed
+	r.observeFees(src.ChainID(), key, fees, len(msgs))
+	r.recordTxCosts(src, dstChainIDs, res, fees)
+	return newRelayerTxResponse(res), err
+}
+
+// broadcastAndWait broadcasts a tx with the given msgs signed by the given key
+// with the grants of src chain, and waits for the tx to be included. The key
+// is only held while the tx is built and broadcast, so that txs of other CZs
+// can be sent while this one is waiting for inclusion. Building and
+// broadcasting the tx is retried according to the given policy. The response
+// and the fees of an included tx are returned even if it failed.
+func (r *Relayer) broadcastAndWait(
+	ctx context.Context,
+	src *relayer.Chain,
+	key string,
+	msgs []provider.RelayerMessage,
+	policy config.RelayPolicy,
+) (*sdk.TxResponse, sdk.Coins, error) {
+	cc, err := cosmosProvider(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := r.sequenceManager(src.ChainID(), key)
+
+	var (
+		txHash string
+		fees   sdk.Coins
+	)
+	if err := retry.Do(func() error {
+		var err error
+		krErr := r.accessKeyWithLock(func() {
+			txHash, fees, err = m.broadcast(ctx, cc, msgs, r.cfg.Global.Memo, r.grants[src.ChainID()])
+		})
+		if krErr != nil {
+			return krErr
+		}
+		return err
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.RetryIf(func(err error) bool {
+		// the account cannot afford any tx until it is refunded, and the msgs
+		// do not fit in a tx however often it is built
+		var gasErr *gasLimitError
+		return ClassifyError(err) != ErrClassInsufficientFunds && !errors.As(err, &gasErr)
+	}), retry.OnRetry(func(n uint, err error) {
+		r.logger.Info(
+			"Failed to build or broadcast tx",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("key", m.key),
+			zap.Uint("attempt", n+1),
+			zap.Uint("max_attempts", policy.Retries),
+			zap.Error(err),
+		)
+	})); err != nil {
+		return nil, nil, err
+	}
+
+	r.logger.Debug(
+		"broadcast tx, waiting for inclusion",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("key", m.key),
+		zap.String("tx_hash", txHash),
+	)
+	res, err := waitForTx(ctx, cc, txHash)
+	return res, fees, err
+}
+
+// queryAccount queries the account number and sequence of the given key on the given chain
+func queryAccount(ctx context.Context, cc *cosmos.CosmosProvider, key string) (uint64, uint64, error) {
+	addr, err := cc.GetKeyAddressForKey(key)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get address of key %s: %w", key, err)
+	}
+	accNum, seq, err := cc.GetAccountNumberSequence(client.Context{}.WithCmdContext(ctx), addr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query account of key %s: %w", key, err)
+	}
+	return accNum, seq, nil
+}
@@ -0,0 +1,62 @@
+package bbnrelayer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAsGasLimitError(t *testing.T) {
+	var gasErr *gasLimitError
+	err := asGasLimitError(errors.New("estimated gas 500000 is higher than max gas 400000"))
+	if !errors.As(err, &gasErr) || gasErr.gas != 500000 || gasErr.limit != 400000 {
+		t.Errorf("got error %v, expected a gas limit error of 500000 gas over 400000", err)
+	}
+
+	otherErr := errors.New("failed to query account")
+	if err := asGasLimitError(otherErr); err != otherErr {
+		t.Errorf("got error %v, expected the error as it is", err)
+	}
+	if !splittable(&gasLimitError{gas: 500000, limit: 400000}) {
+		t.Error("a batch over the gas limit is not splittable")
+	}
+}
+
+func TestEstimateGas(t *testing.T) {
+	m := newSequenceManager(testBabylonChainID, "relayer")
+	simulations := 0
+	simulate := func(gas uint64, err error) func() (uint64, error) {
+		return func() (uint64, error) {
+			simulations++
+			return gas, err
+		}
+	}
+
+	// a tx ahead of the committed state without an estimate is simulated
+	gas, err := m.estimateGasLocked(11, 10, 2, simulate(200001, nil))
+	if err != nil || gas != 200001 || simulations != 1 {
+		t.Errorf("got gas %d with error %v after %d simulations, expected the simulated gas 200001", gas, err, simulations)
+	}
+
+	// a tx ahead of the committed state reuses the estimate of the last
+	// simulated tx, as the node might simulate against the committed state
+	gas, err = m.estimateGasLocked(12, 10, 3, simulate(0, errors.New("account sequence mismatch, expected 10, got 12: incorrect account sequence")))
+	if err != nil || gas != 300003 || simulations != 1 {
+		t.Errorf("got gas %d with error %v after %d simulations, expected the estimated gas 300003 without simulation", gas, err, simulations)
+	}
+
+	// a tx at the committed sequence is always simulated
+	gas, err = m.estimateGasLocked(10, 10, 1, simulate(50000, nil))
+	if err != nil || gas != 50000 || simulations != 2 {
+		t.Errorf("got gas %d with error %v after %d simulations, expected the simulated gas 50000", gas, err, simulations)
+	}
+	errFailed := errors.New("failed to simulate")
+	if _, err := m.estimateGasLocked(10, 10, 1, simulate(0, errFailed)); err != errFailed {
+		t.Errorf("got error %v, expected the error of the simulation", err)
+	}
+
+	// the estimate is updated by the last successful simulation
+	gas, err = m.estimateGasLocked(11, 10, 2, simulate(0, errFailed))
+	if err != nil || gas != 100000 {
+		t.Errorf("got gas %d with error %v, expected the estimated gas 100000", gas, err)
+	}
+}
@@ -0,0 +1,167 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/babylonchain/babylon-relayer/config"
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+	relayercmd "github.com/cosmos/relayer/v2/cmd"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/provider"
+	"go.uber.org/zap"
+)
+
+const (
+	testBabylonChainID = "bbn-test-3"
+	testCZChainID      = "czchain"
+	testClientID       = "07-tendermint-0"
+)
+
+// fakeProvider is a chain provider that serves a fixed latest height, and the
+// client of the CZ on the Babylon chain unless noClient is set. Other methods
+// are not implemented.
+type fakeProvider struct {
+	provider.ChainProvider
+	chainID  string
+	height   int64
+	noClient bool
+}
+
+func (p *fakeProvider) ChainId() string         { return p.chainID }
+func (p *fakeProvider) ChainName() string       { return p.chainID }
+func (p *fakeProvider) Key() string             { return "relayer" }
+func (p *fakeProvider) KeyExists(_ string) bool { return true }
+
+func (p *fakeProvider) QueryLatestHeight(context.Context) (int64, error) {
+	return p.height, nil
+}
+
+func (p *fakeProvider) QueryClientState(_ context.Context, _ int64, clientID string) (ibcexported.ClientState, error) {
+	if p.noClient {
+		return nil, fmt.Errorf("client %s: %w", clientID, clienttypes.ErrClientNotFound)
+	}
+	return &ibctm.ClientState{
+		ChainId:        testCZChainID,
+		TrustingPeriod: time.Hour,
+		LatestHeight:   clienttypes.NewHeight(0, 5),
+	}, nil
+}
+
+func (p *fakeProvider) QueryClientConsensusState(context.Context, int64, string, ibcexported.Height) (*clienttypes.QueryConsensusStateResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *fakeProvider) QueryIBCHeader(_ context.Context, h int64) (provider.IBCHeader, error) {
+	return fakeHeader(h), nil
+}
+
+func (p *fakeProvider) MsgUpdateClientHeader(provider.IBCHeader, clienttypes.Height, provider.IBCHeader) (ibcexported.ClientMessage, error) {
+	return &ibctm.Header{}, nil
+}
+
+func (p *fakeProvider) MsgUpdateClient(string, ibcexported.ClientMessage) (provider.RelayerMessage, error) {
+	return fakeMsg{}, nil
+}
+
+// fakeHeader is a header at the given height without a chain ID
+type fakeHeader uint64
+
+func (h fakeHeader) Height() uint64                             { return uint64(h) }
+func (h fakeHeader) ConsensusState() ibcexported.ConsensusState { return &ibctm.ConsensusState{} }
+func (h fakeHeader) NextValidatorsHash() []byte                 { return nil }
+
+type fakeMsg struct{}
+
+func (fakeMsg) Type() string              { return "/ibc.core.client.v1.MsgUpdateClient" }
+func (fakeMsg) MsgBytes() ([]byte, error) { return nil, nil }
+
+// TestShutdownDuringInFlightTx checks that a tx in flight upon shutdown is
+// allowed to finish within the grace period, and that its results are
+// recorded before KeepUpdatingClients returns
+func TestShutdownDuringInFlightTx(t *testing.T) {
+	const gracePeriod = 2 * time.Second
+
+	src := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testBabylonChainID, height: 100}, false)
+	dst := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testCZChainID, height: 11}, false)
+	cfg := &relayercmd.Config{Chains: relayer.Chains{"babylon": src, "cz": dst}}
+
+	clientStore := NewMemClientStore()
+	if err := clientStore.Set(testBabylonChainID, testCZChainID, &ClientRecord{ClientID: testClientID, BabylonChainID: testBabylonChainID}); err != nil {
+		t.Fatal(err)
+	}
+	costStore := NewMemCostStore()
+	r := New("", cfg, zap.NewNop(), relaydebug.NewPrometheusMetrics(), clientStore, costStore, gracePeriod)
+
+	// the tx is in flight until after shutdown, and fails if its context is
+	// cancelled within the grace period
+	sending := make(chan struct{})
+	shutdown := make(chan struct{})
+	var once sync.Once
+	r.broadcastTx = func(ctx context.Context, _ *relayer.Chain, _ string, _ []provider.RelayerMessage, _ config.RelayPolicy) (*sdk.TxResponse, sdk.Coins, error) {
+		once.Do(func() { close(sending) })
+		<-shutdown
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+		return &sdk.TxResponse{TxHash: "TXHASH", Height: 101, GasUsed: 1000, GasWanted: 2000}, sdk.NewCoins(sdk.NewCoin("ubbn", sdkmath.NewInt(10))), nil
+	}
+
+	babylonCfg := &config.BabylonConfig{Chains: []*config.BabylonChainConfig{{
+		ChainName: "babylon",
+		CZs: []*config.CZConfig{{
+			ChainName:   "cz",
+			RelayPolicy: config.RelayPolicy{Interval: time.Minute, Retries: 1, RetryDelay: time.Millisecond, Mode: config.RelayModeInterval},
+		}},
+	}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	r.KeepUpdatingClients(ctx, &wg, babylonCfg, DefaultSupervisorConfig())
+
+	select {
+	case <-sending:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the client update was not sent")
+	}
+	cancel()
+	close(shutdown)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(gracePeriod):
+		t.Fatalf("KeepUpdatingClients did not return within the grace period %v", gracePeriod)
+	}
+
+	record, err := clientStore.Get(testBabylonChainID, testCZChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.LastUpdateTxHash != "TXHASH" || record.LastRelayedHeight != 11 {
+		t.Errorf("got last update tx %q at height %d, expected TXHASH at height 11", record.LastUpdateTxHash, record.LastRelayedHeight)
+	}
+	costs, err := costStore.List(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costs) != 1 || costs[0].TxHash != "TXHASH" || costs[0].CZChainID != testCZChainID {
+		t.Errorf("got %d cost records, expected the one of the tx in flight", len(costs))
+	}
+}
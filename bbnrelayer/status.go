@@ -0,0 +1,121 @@
+package bbnrelayer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cosmos/relayer/v2/relayer"
+)
+
+// CZStatus is the health of the timestamping of a CZ on a Babylon chain
+type CZStatus struct {
+	BabylonChainID string `json:"babylon_chain_id" yaml:"babylon_chain_id"`
+	ChainName      string `json:"chain_name" yaml:"chain_name"`
+	ChainID        string `json:"chain_id" yaml:"chain_id"`
+	// ClientID is the client of the CZ stored by the relayer, and
+	// ClientStatus its status on the Babylon chain
+	ClientID     string `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientStatus string `json:"client_status,omitempty" yaml:"client_status,omitempty"`
+	// CZLatestHeight is the latest height of the CZ, and ClientLatestHeight
+	// the height of the latest header in the client
+	CZLatestHeight     int64  `json:"cz_latest_height,omitempty" yaml:"cz_latest_height,omitempty"`
+	ClientLatestHeight uint64 `json:"client_latest_height,omitempty" yaml:"client_latest_height,omitempty"`
+	// LastConsensusTime is the timestamp of the latest consensus state in the
+	// client, and SinceLastConsensus the time elapsed since then
+	LastConsensusTime  *time.Time    `json:"last_consensus_time,omitempty" yaml:"last_consensus_time,omitempty"`
+	SinceLastConsensus time.Duration `json:"since_last_consensus,omitempty" yaml:"since_last_consensus,omitempty"`
+	// TrustRemaining is the remaining trusting period of the client, which
+	// is not positive once the client expires
+	TrustRemaining time.Duration `json:"trust_remaining,omitempty" yaml:"trust_remaining,omitempty"`
+	// Payers are the accounts that pay the fees of the client updates of
+	// the CZ, with their balances on the Babylon chain
+	Payers []*PayerBalance `json:"payers,omitempty" yaml:"payers,omitempty"`
+	// Errors are the errors of the queries that failed, in which case the
+	// corresponding fields are left empty
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// PayerBalance is the balance of an account that pays the fees of client updates
+type PayerBalance struct {
+	// Name is the name of the key, or the address of the fee granter
+	Name    string `json:"name" yaml:"name"`
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+	Balance string `json:"balance,omitempty" yaml:"balance,omitempty"`
+}
+
+// QueryCZStatus queries the health of the client of the given CZ on the given
+// Babylon chain stored in the given store, where the balances of the given
+// fee payers on the Babylon chain are reported. Failed queries are reported in
+// the errors of the status rather than returned, so that a status is always
+// returned.
+func QueryCZStatus(
+	ctx context.Context,
+	store ClientStore,
+	babylonChain *relayer.Chain,
+	czChain *relayer.Chain,
+	payers []FeePayer,
+) *CZStatus {
+	status := &CZStatus{
+		BabylonChainID: babylonChain.ChainID(),
+		ChainName:      czChain.ChainProvider.ChainName(),
+		ChainID:        czChain.ChainID(),
+	}
+	addError := func(err error) {
+		status.Errors = append(status.Errors, err.Error())
+	}
+
+	if h, err := czChain.ChainProvider.QueryLatestHeight(ctx); err != nil {
+		addError(fmt.Errorf("failed to query latest height of chain %s: %w", czChain.ChainID(), err))
+	} else {
+		status.CZLatestHeight = h
+	}
+
+	if record, err := store.Get(babylonChain.ChainID(), czChain.ChainID()); err != nil {
+		addError(fmt.Errorf("failed to get client of chain %s: %w", czChain.ChainID(), err))
+	} else {
+		status.ClientID = record.ClientID
+		if info, err := QueryClientInfo(ctx, babylonChain, record.ClientID); err != nil {
+			addError(err)
+		} else {
+			status.ClientStatus = info.Status
+			status.ClientLatestHeight = info.LatestHeight.RevisionHeight
+			status.LastConsensusTime = &info.LatestConsensusTimestamp
+			status.SinceLastConsensus = time.Since(info.LatestConsensusTimestamp).Truncate(time.Second)
+			status.TrustRemaining = info.TrustingPeriod - status.SinceLastConsensus
+		}
+	}
+
+	for _, payer := range payers {
+		balance := &PayerBalance{Name: payer.Name, Address: payer.Address}
+		status.Payers = append(status.Payers, balance)
+		if err := queryPayerBalance(ctx, babylonChain, balance); err != nil {
+			addError(err)
+		}
+	}
+	return status
+}
+
+// queryPayerBalance queries the balance of the given payer, and its address
+// if it is a key
+func queryPayerBalance(ctx context.Context, babylonChain *relayer.Chain, payer *PayerBalance) error {
+	cc, err := cosmosProvider(babylonChain)
+	if err != nil {
+		return err
+	}
+	if len(payer.Address) == 0 {
+		addr, err := cc.GetKeyAddressForKey(payer.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get address of key %s: %w", payer.Name, err)
+		}
+		if payer.Address, err = cc.EncodeBech32AccAddr(addr); err != nil {
+			return fmt.Errorf("failed to encode address of key %s: %w", payer.Name, err)
+		}
+	}
+	balance, err := cc.QueryBalanceWithAddress(ctx, payer.Address)
+	if err != nil {
+		return fmt.Errorf("failed to query balance of %s: %w", payer.Address, err)
+	}
+	payer.Balance = balance.String()
+	return nil
+}
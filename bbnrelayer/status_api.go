@@ -0,0 +1,174 @@
+package bbnrelayer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	"go.uber.org/zap"
+)
+
+var _ relaydebug.StatusAPI = (*Relayer)(nil)
+
+// RelayerStatus is the status of the relaying loop of every chain
+type RelayerStatus struct {
+	Time time.Time `json:"time"`
+	// Healthy is whether every chain is running with an active client
+	Healthy bool          `json:"healthy"`
+	Chains  []ChainStatus `json:"chains"`
+}
+
+// ClientStatus is the health of the client on src chain that tracks dst chain
+type ClientStatus struct {
+	SrcChainID string `json:"src_chain_id"`
+	DstChainID string `json:"dst_chain_id"`
+	ClientHealth
+}
+
+// Status returns the status of the relaying loop of every chain
+func (r *Relayer) Status() RelayerStatus {
+	status := RelayerStatus{
+		Time:    time.Now().UTC(),
+		Healthy: true,
+		Chains:  r.ChainStatuses(),
+	}
+	for _, chain := range status.Chains {
+		if !chain.healthy() {
+			status.Healthy = false
+		}
+	}
+	return status
+}
+
+// healthy returns whether the relaying loop of the chain is running and its
+// client is neither inactive nor halted
+func (s ChainStatus) healthy() bool {
+	return s.State == ChainStateRunning &&
+		s.Client.Status != ibcexported.Expired.String() &&
+		s.Client.Status != ibcexported.Frozen.String() &&
+		len(s.Client.Halted) == 0
+}
+
+// ServeStatus serves the status of the relaying loop of every chain
+func (r *Relayer) ServeStatus(w http.ResponseWriter, req *http.Request) {
+	if !r.allowRead(w, req) {
+		return
+	}
+	r.writeJSON(w, http.StatusOK, r.Status())
+}
+
+// ServeChain serves the status of the relaying loops of the chain in the path
+// /chains/{chain}, where the chain is given by its name in config or its chain
+// ID, and is either the Babylon chain or the CZ of a loop
+func (r *Relayer) ServeChain(w http.ResponseWriter, req *http.Request) {
+	if !r.allowRead(w, req) {
+		return
+	}
+	chain := strings.Trim(strings.TrimPrefix(req.URL.Path, "/chains/"), "/")
+	if len(chain) == 0 {
+		r.writeJSON(w, http.StatusNotFound, apiError{Error: "chain is not specified, expected /chains/{chain}"})
+		return
+	}
+	chainID := chain
+	if c, ok := r.cfg.Chains[chain]; ok {
+		chainID = c.ChainID()
+	}
+
+	statuses := []ChainStatus{}
+	for _, status := range r.ChainStatuses() {
+		if status.SrcChainID == chainID || status.DstChainID == chainID {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		r.writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("chain %s is not relayed", chain)})
+		return
+	}
+	r.writeJSON(w, http.StatusOK, statuses)
+}
+
+// ServeClients serves the health of the client of every chain
+func (r *Relayer) ServeClients(w http.ResponseWriter, req *http.Request) {
+	if !r.allowRead(w, req) {
+		return
+	}
+	chainStatuses := r.ChainStatuses()
+	clients := make([]ClientStatus, 0, len(chainStatuses))
+	for _, status := range chainStatuses {
+		clients = append(clients, ClientStatus{
+			SrcChainID:   status.SrcChainID,
+			DstChainID:   status.DstChainID,
+			ClientHealth: status.Client,
+		})
+	}
+	r.writeJSON(w, http.StatusOK, clients)
+}
+
+// ServeRecords serves the client record of every CZ stored by the relayer,
+// in the format of `clients export`
+func (r *Relayer) ServeRecords(w http.ResponseWriter, req *http.Request) {
+	if !r.allowRead(w, req) {
+		return
+	}
+	records, err := r.clientStore.List()
+	if err != nil {
+		r.writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	r.writeJSON(w, http.StatusOK, records)
+}
+
+// ServeCosts serves the cost records stored by the relayer since the RFC3339
+// time in the query parameter since, or all of them if it is not specified
+func (r *Relayer) ServeCosts(w http.ResponseWriter, req *http.Request) {
+	if !r.allowRead(w, req) {
+		return
+	}
+	var since time.Time
+	if s := req.URL.Query().Get("since"); len(s) > 0 {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			r.writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid time %q, expected an RFC3339 time", s)})
+			return
+		}
+	}
+	records, err := r.costStore.List(since)
+	if err != nil {
+		r.writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	if records == nil {
+		records = []*CostRecord{}
+	}
+	r.writeJSON(w, http.StatusOK, records)
+}
+
+// apiError is the body of a failed request to the status API
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// allowRead rejects requests to the status API other than GET and HEAD
+func (r *Relayer) allowRead(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	r.writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: fmt.Sprintf("method %s is not allowed", req.Method)})
+	return false
+}
+
+// writeJSON writes the given object as the JSON body of a response with the given code
+func (r *Relayer) writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(obj); err != nil {
+		r.logger.Debug("failed to write response of the status API", zap.Error(err))
+	}
+}
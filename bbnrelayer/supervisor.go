@@ -0,0 +1,312 @@
+package bbnrelayer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	"go.uber.org/zap"
+)
+
+// ChainState is the state of the relaying loop of a chain
+type ChainState string
+
+const (
+	// ChainStateRunning means the relaying loop of the chain is running
+	ChainStateRunning ChainState = "running"
+	// ChainStateBackingOff means the relaying loop of the chain has failed
+	// and is waiting to be restarted
+	ChainStateBackingOff ChainState = "backing-off"
+	// ChainStateGivenUp means the relaying loop of the chain has failed too
+	// many times and the supervisor will no longer restart it
+	ChainStateGivenUp ChainState = "given-up"
+	// ChainStateStopped means the relaying loop of the chain has exited
+	// without error, e.g., upon shutdown
+	ChainStateStopped ChainState = "stopped"
+)
+
+var allChainStates = []ChainState{ChainStateRunning, ChainStateBackingOff, ChainStateGivenUp, ChainStateStopped}
+
+// SupervisorConfig specifies how the supervisor restarts failed relaying loops
+type SupervisorConfig struct {
+	// InitialBackoff is the delay before the first restart of a failed loop
+	InitialBackoff time.Duration
+	// MaxBackoff is the upper bound of the exponentially growing delay
+	MaxBackoff time.Duration
+	// MaxRestarts is the maximum number of restarts within RestartWindow,
+	// after which the supervisor gives up on the chain
+	MaxRestarts int
+	// RestartWindow is the sliding window in which restarts are counted
+	RestartWindow time.Duration
+}
+
+// DefaultSupervisorConfig returns the default supervisor config
+func DefaultSupervisorConfig() SupervisorConfig {
+	return SupervisorConfig{
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     10 * time.Minute,
+		MaxRestarts:    10,
+		RestartWindow:  time.Hour,
+	}
+}
+
+// ChainStatus is a snapshot of the relaying loop of a chain
+type ChainStatus struct {
+	SrcChainID      string     `json:"src_chain_id"`
+	DstChainID      string     `json:"dst_chain_id"`
+	State           ChainState `json:"state"`
+	Restarts        uint       `json:"restarts"`
+	LastFailure     string     `json:"last_failure,omitempty"`
+	LastFailureTime time.Time  `json:"last_failure_time,omitempty"`
+	NextRestartTime time.Time  `json:"next_restart_time,omitempty"`
+
+	// LastSuccessTime is the time of the last successful client update, and
+	// LastError the error of the last failed one, which is cleared upon success
+	LastSuccessTime     time.Time `json:"last_success_time,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorClass      string    `json:"last_error_class,omitempty"`
+	LastErrorTime       time.Time `json:"last_error_time,omitempty"`
+	ConsecutiveFailures uint      `json:"consecutive_failures"`
+	// NextUpdateTime is when the next client update is scheduled, while the loop is running
+	NextUpdateTime time.Time `json:"next_update_time,omitempty"`
+
+	// Client is the health of the client as of the last time it was queried
+	Client ClientHealth `json:"client"`
+}
+
+// ClientHealth is the health of the client on src chain that tracks dst chain,
+// as last observed by the relaying loop of the chain
+type ClientHealth struct {
+	ClientID string `json:"client_id,omitempty"`
+	Status   string `json:"status,omitempty"`
+	// Halted is the error with which the client is halted after being
+	// found misbehaving, if any
+	Halted string `json:"halted,omitempty"`
+	// LatestHeight is the height of the latest header in the client, whose
+	// consensus state has the timestamp LatestConsensusTime
+	LatestHeight        uint64        `json:"latest_height,omitempty"`
+	LatestConsensusTime time.Time     `json:"latest_consensus_time,omitempty"`
+	TrustingPeriod      time.Duration `json:"trusting_period,omitempty"`
+	// TrustRemaining is the remaining trusting period of the client when the
+	// snapshot is taken, which is not positive once the client expires
+	TrustRemaining time.Duration `json:"trust_remaining,omitempty"`
+	// LastRelayedHeight is the CZ height of the last successfully relayed header
+	LastRelayedHeight int64 `json:"last_relayed_height,omitempty"`
+	// CheckedTime is the time the client was last queried
+	CheckedTime time.Time `json:"checked_time,omitempty"`
+}
+
+type chainPair struct {
+	src string
+	dst string
+}
+
+// supervisor runs the relaying loop of each chain and restarts it with
+// exponential backoff upon failures
+type supervisor struct {
+	cfg     SupervisorConfig
+	logger  *zap.Logger
+	metrics *relaydebug.PrometheusMetrics
+
+	mu       sync.RWMutex
+	statuses map[chainPair]*ChainStatus
+}
+
+func newSupervisor(cfg SupervisorConfig, logger *zap.Logger, metrics *relaydebug.PrometheusMetrics) *supervisor {
+	return &supervisor{
+		cfg:      cfg,
+		logger:   logger,
+		metrics:  metrics,
+		statuses: map[chainPair]*ChainStatus{},
+	}
+}
+
+// supervise runs the given loop until it exits without error or ctx is done.
+// Upon each failure, the loop is restarted after an exponentially growing delay,
+// unless the error is unrecoverable or it has been restarted more than MaxRestarts
+// times within RestartWindow.
+func (s *supervisor) supervise(ctx context.Context, srcChainID string, dstChainID string, loop func(ctx context.Context) error) {
+	key := chainPair{src: srcChainID, dst: dstChainID}
+	backoff := s.cfg.InitialBackoff
+	var restarts []time.Time
+
+	for {
+		s.setState(key, ChainStateRunning, time.Time{})
+		startTime := time.Now()
+		err := loop(ctx)
+		if err == nil || ctx.Err() != nil {
+			s.setState(key, ChainStateStopped, time.Time{})
+			return
+		}
+
+		now := time.Now()
+		s.recordFailure(key, err, now)
+		s.metrics.FailedChainsCounter.WithLabelValues(srcChainID, dstChainID).Inc()
+
+		// the loop has been healthy for a whole window, so start over
+		if now.Sub(startTime) > s.cfg.RestartWindow {
+			backoff = s.cfg.InitialBackoff
+		}
+
+		// only count the restarts within the sliding window
+		windowStart := now.Add(-s.cfg.RestartWindow)
+		for len(restarts) > 0 && restarts[0].Before(windowStart) {
+			restarts = restarts[1:]
+		}
+		// restarting does not help if the error is unrecoverable
+		if IsUnrecoverable(err) || len(restarts) >= s.cfg.MaxRestarts {
+			s.setState(key, ChainStateGivenUp, time.Time{})
+			s.logger.Error(
+				"chain failed with unrecoverable error or too many times. Give up relaying the chain",
+				zap.String("src_chain_id", srcChainID),
+				zap.String("dst_chain_id", dstChainID),
+				zap.Int("restarts_in_window", len(restarts)),
+				zap.Duration("restart_window", s.cfg.RestartWindow),
+				zap.Error(err),
+			)
+			return
+		}
+
+		nextRestart := now.Add(backoff)
+		s.setState(key, ChainStateBackingOff, nextRestart)
+		s.logger.Warn(
+			"chain failed. Restarting relaying the chain after backoff",
+			zap.String("src_chain_id", srcChainID),
+			zap.String("dst_chain_id", dstChainID),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			s.setState(key, ChainStateStopped, time.Time{})
+			return
+		case <-time.After(backoff):
+		}
+
+		restarts = append(restarts, time.Now())
+		s.incRestarts(key)
+		s.metrics.RestartedChainsCounter.WithLabelValues(srcChainID, dstChainID).Inc()
+
+		backoff *= 2
+		if backoff > s.cfg.MaxBackoff {
+			backoff = s.cfg.MaxBackoff
+		}
+	}
+}
+
+func (s *supervisor) getOrCreateStatus(key chainPair) *ChainStatus {
+	status, ok := s.statuses[key]
+	if !ok {
+		// a chain relayed without supervision is running while it reports its updates
+		status = &ChainStatus{SrcChainID: key.src, DstChainID: key.dst, State: ChainStateRunning}
+		s.statuses[key] = status
+	}
+	return status
+}
+
+func (s *supervisor) setState(key chainPair, state ChainState, nextRestart time.Time) {
+	s.mu.Lock()
+	status := s.getOrCreateStatus(key)
+	status.State = state
+	status.NextRestartTime = nextRestart
+	if state != ChainStateRunning {
+		status.NextUpdateTime = time.Time{}
+	}
+	s.mu.Unlock()
+
+	for _, st := range allChainStates {
+		value := 0.0
+		if st == state {
+			value = 1
+		}
+		s.metrics.ChainStateGauge.WithLabelValues(key.src, key.dst, string(st)).Set(value)
+	}
+}
+
+func (s *supervisor) recordFailure(key chainPair, err error, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.getOrCreateStatus(key)
+	status.LastFailure = err.Error()
+	status.LastFailureTime = t
+}
+
+func (s *supervisor) incRestarts(key chainPair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.getOrCreateStatus(key).Restarts++
+}
+
+// recordUpdate records the result of a client update of the given chains at the given time
+func (s *supervisor) recordUpdate(key chainPair, err error, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.getOrCreateStatus(key)
+	if err == nil {
+		status.LastSuccessTime = t
+		status.LastError = ""
+		status.LastErrorClass = ""
+		status.ConsecutiveFailures = 0
+		return
+	}
+	status.LastError = err.Error()
+	status.LastErrorClass = string(ClassifyError(err))
+	status.LastErrorTime = t
+	if ClassifyError(err).CountsAsFailure() {
+		status.ConsecutiveFailures++
+	}
+}
+
+// setNextUpdate records when the next client update of the given chains is scheduled
+func (s *supervisor) setNextUpdate(key chainPair, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.getOrCreateStatus(key).NextUpdateTime = t
+}
+
+// recordClientStatus records the given client of the given chains and its status
+func (s *supervisor) recordClientStatus(key chainPair, clientID string, clientStatus string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client := &s.getOrCreateStatus(key).Client
+	client.ClientID = clientID
+	client.Status = clientStatus
+	client.CheckedTime = t
+}
+
+// recordClientInfo records the given client of the given chains, its status,
+// trust window and the height of the last header relayed to it
+func (s *supervisor) recordClientInfo(key chainPair, record *ClientRecord, info *ClientInfo, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client := &s.getOrCreateStatus(key).Client
+	client.ClientID = record.ClientID
+	client.Status = info.Status
+	client.LastRelayedHeight = record.LastRelayedHeight
+	client.LatestHeight = info.LatestHeight.RevisionHeight
+	client.LatestConsensusTime = info.LatestConsensusTimestamp
+	client.TrustingPeriod = info.TrustingPeriod
+	client.CheckedTime = t
+}
+
+// chainStatuses returns a snapshot of the status of all supervised chains,
+// sorted by src and dst chain IDs
+func (s *supervisor) chainStatuses() []ChainStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]ChainStatus, 0, len(s.statuses))
+	for _, status := range s.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].SrcChainID != statuses[j].SrcChainID {
+			return statuses[i].SrcChainID < statuses[j].SrcChainID
+		}
+		return statuses[i].DstChainID < statuses[j].DstChainID
+	})
+	return statuses
+}
@@ -0,0 +1,130 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	"go.uber.org/zap"
+)
+
+// waitForChainState waits until the relaying loop of the given chains is in
+// the given state, and returns a snapshot of its status
+func waitForChainState(t *testing.T, s *supervisor, key chainPair, state ChainState) ChainStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.RLock()
+		status := *s.getOrCreateStatus(key)
+		s.mu.RUnlock()
+		if status.State == state {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("chain is %s rather than %s", status.State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervise(t *testing.T) {
+	cfg := SupervisorConfig{
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		MaxRestarts:    2,
+		RestartWindow:  time.Hour,
+	}
+	s := newSupervisor(cfg, zap.NewNop(), relaydebug.NewPrometheusMetrics())
+	key := chainPair{src: "bbn-test-3", dst: "osmo-test-5"}
+
+	// each run of the loop fails once the test lets it
+	runs := make(chan struct{})
+	failures := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.supervise(context.Background(), key.src, key.dst, func(ctx context.Context) error {
+			runs <- struct{}{}
+			return <-failures
+		})
+	}()
+
+	wantBackoffs := []time.Duration{200 * time.Millisecond, 300 * time.Millisecond}
+	for i, backoff := range wantBackoffs {
+		<-runs
+		if status := waitForChainState(t, s, key, ChainStateRunning); status.Restarts != uint(i) {
+			t.Errorf("got %d restarts in run %d, expected %d", status.Restarts, i, i)
+		}
+		failures <- fmt.Errorf("failure %d", i)
+
+		// the backoff grows exponentially up to the max backoff
+		status := waitForChainState(t, s, key, ChainStateBackingOff)
+		if status.LastFailure != fmt.Sprintf("failure %d", i) {
+			t.Errorf("got last failure %q, expected failure %d", status.LastFailure, i)
+		}
+		if got := status.NextRestartTime.Sub(status.LastFailureTime); got != backoff {
+			t.Errorf("got backoff %v after failure %d, expected %v", got, i, backoff)
+		}
+	}
+
+	// the loop is not restarted more than MaxRestarts times within the window
+	<-runs
+	failures <- errors.New("failure 2")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the supervisor did not give up after too many restarts")
+	}
+	status := waitForChainState(t, s, key, ChainStateGivenUp)
+	if status.Restarts != 2 || status.LastFailure != "failure 2" {
+		t.Errorf("got %d restarts and last failure %q, expected 2 restarts and failure 2", status.Restarts, status.LastFailure)
+	}
+
+	// restarting does not help upon an unrecoverable error
+	key = chainPair{src: "bbn-test-3", dst: "juno-1"}
+	runCount := 0
+	start := time.Now()
+	s.supervise(context.Background(), key.src, key.dst, func(ctx context.Context) error {
+		runCount++
+		return newUpdateClientError(ErrClassClientFrozen, clienttypes.ErrClientFrozen)
+	})
+	if elapsed := time.Since(start); elapsed >= cfg.InitialBackoff {
+		t.Errorf("the supervisor gave up after %v, expected no backoff", elapsed)
+	}
+	status = waitForChainState(t, s, key, ChainStateGivenUp)
+	if runCount != 1 || status.Restarts != 0 || len(status.LastFailure) == 0 {
+		t.Errorf("got %d runs, %d restarts and last failure %q upon an unrecoverable error, expected a single failed run", runCount, status.Restarts, status.LastFailure)
+	}
+}
+
+func TestSupervisorRecordUpdate(t *testing.T) {
+	s := newSupervisor(DefaultSupervisorConfig(), zap.NewNop(), relaydebug.NewPrometheusMetrics())
+	key := chainPair{src: "bbn-test-3", dst: "osmo-test-5"}
+	now := time.Now()
+
+	s.recordUpdate(key, errors.New("connection refused"), now)
+	s.recordUpdate(key, newUpdateClientError(ErrClassTransient, errors.New("timeout")), now)
+	if got := s.getOrCreateStatus(key).ConsecutiveFailures; got != 2 {
+		t.Errorf("got %d consecutive failures after 2 failures, expected 2", got)
+	}
+
+	// a halted CZ is not a failure of the relayer
+	s.recordUpdate(key, newUpdateClientError(ErrClassChainHalted, errors.New("no new block")), now)
+	status := s.getOrCreateStatus(key)
+	if status.ConsecutiveFailures != 2 {
+		t.Errorf("got %d consecutive failures after a halted CZ, expected 2", status.ConsecutiveFailures)
+	}
+	if status.LastErrorClass != string(ErrClassChainHalted) {
+		t.Errorf("got last error class %q, expected %q", status.LastErrorClass, ErrClassChainHalted)
+	}
+
+	s.recordUpdate(key, nil, now)
+	status = s.getOrCreateStatus(key)
+	if status.ConsecutiveFailures != 0 || len(status.LastError) > 0 || !status.LastSuccessTime.Equal(now) {
+		t.Errorf("got status %+v after a success, expected no failures", status)
+	}
+}
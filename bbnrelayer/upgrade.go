@@ -0,0 +1,387 @@
+package bbnrelayer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	upgradetypes "cosmossdk.io/x/upgrade/types"
+	"github.com/babylonchain/babylon-relayer/config"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/chains/cosmos"
+	"github.com/cosmos/relayer/v2/relayer/provider"
+	"go.uber.org/zap"
+)
+
+const (
+	// UpgradeMethodMsgUpgradeClient means the client of the previous revision
+	// is upgraded to the new revision by a MsgUpgradeClient
+	UpgradeMethodMsgUpgradeClient = "msg_upgrade_client"
+	// UpgradeMethodNewClient means a new client is created for the new revision
+	UpgradeMethodNewClient = "new_client"
+)
+
+// ClientUpgrade is an upgrade of a CZ to a new revision, i.e., a chain ID
+// with a higher revision number, that the relayer has followed
+type ClientUpgrade struct {
+	// PreviousChainID and PreviousClientID are the chain ID of the previous
+	// revision and the client that tracked it
+	PreviousChainID  string `json:"previous_chain_id"`
+	PreviousClientID string `json:"previous_client_id"`
+	// ChainID and ClientID are the chain ID of the new revision and the client
+	// that tracks it, which is the previous client if upgraded by MsgUpgradeClient
+	ChainID  string `json:"chain_id"`
+	ClientID string `json:"client_id"`
+	// Method is how the relayer followed the upgrade
+	Method string `json:"method"`
+	// PlanHeight is the height of the upgrade plan of the previous revision,
+	// which is only set if upgraded by MsgUpgradeClient
+	PlanHeight int64     `json:"plan_height,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// revisionUpgradeError means that the node of a CZ serves a new revision of
+// the CZ, i.e., the CZ has upgraded while being relayed
+type revisionUpgradeError struct {
+	chainID    string
+	newChainID string
+}
+
+func (e *revisionUpgradeError) Error() string {
+	return fmt.Sprintf("node of chain %s serves its new revision %s", e.chainID, e.newChainID)
+}
+
+// isPreviousRevision returns whether the given chain ID is a previous revision
+// of the given chain ID, e.g., osmo-test-4 of osmo-test-5
+func isPreviousRevision(previousChainID string, chainID string) bool {
+	if !clienttypes.IsRevisionFormat(previousChainID) || !clienttypes.IsRevisionFormat(chainID) {
+		return false
+	}
+	previousRevision, revision := clienttypes.ParseChainID(previousChainID), clienttypes.ParseChainID(chainID)
+	return previousRevision < revision &&
+		strings.TrimSuffix(previousChainID, fmt.Sprintf("-%d", previousRevision)) == strings.TrimSuffix(chainID, fmt.Sprintf("-%d", revision))
+}
+
+// previousRevisionRecord returns the chain ID and the record of the latest
+// previous revision of dst chain with a client on src chain in DB, if any
+func (r *Relayer) previousRevisionRecord(srcChainID string, dstChainID string) (string, *ClientRecord, error) {
+	records, err := r.clientStore.List()
+	if err != nil {
+		return "", nil, err
+	}
+	var (
+		previousChainID string
+		previous        *ClientRecord
+	)
+	for key, record := range records {
+		if key.BabylonChainID != srcChainID {
+			continue
+		}
+		if !isPreviousRevision(key.CZChainID, dstChainID) {
+			continue
+		}
+		if previous == nil || clienttypes.ParseChainID(key.CZChainID) > clienttypes.ParseChainID(previousChainID) {
+			previousChainID, previous = key.CZChainID, record
+		}
+	}
+	return previousChainID, previous, nil
+}
+
+// followedRevision returns the chain of the new revision of dst chain that
+// the relayer has followed dst chain to, i.e., the latest new revision with a
+// client on src chain in DB while dst chain has none, or dst chain otherwise
+func (r *Relayer) followedRevision(ctx context.Context, src *relayer.Chain, dst *relayer.Chain) (*relayer.Chain, error) {
+	records, err := r.clientStore.List()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := records[ClientKey{BabylonChainID: src.ChainID(), CZChainID: dst.ChainID()}]; ok {
+		return dst, nil
+	}
+	var newChainID string
+	for key := range records {
+		if key.BabylonChainID != src.ChainID() || !isPreviousRevision(dst.ChainID(), key.CZChainID) {
+			continue
+		}
+		if len(newChainID) == 0 || clienttypes.ParseChainID(key.CZChainID) > clienttypes.ParseChainID(newChainID) {
+			newChainID = key.CZChainID
+		}
+	}
+	if len(newChainID) == 0 {
+		return dst, nil
+	}
+
+	newDst, err := r.revisionChain(ctx, dst, newChainID)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.Warn(
+		"The relayer has followed the CZ to a new revision that is not in config. Relaying the new revision, update its chain ID in config",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("new_chain_id", newChainID),
+	)
+	return newDst, nil
+}
+
+// followUpgrade follows dst chain to its new revision from the client of its
+// given previous revision on src chain. The client is upgraded by a
+// MsgUpgradeClient if the RPC address of the previous revision is configured
+// and it has an IBC upgrade plan, otherwise a new client is created. The
+// upgrade is recorded in the lineage of the resulting client, which replaces
+// the record of the previous revision in DB.
+func (r *Relayer) followUpgrade(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	previousChainID string,
+	previous *ClientRecord,
+	srch int64,
+	dsth int64,
+	policy config.RelayPolicy,
+	clientCfg config.ClientConfig,
+	upgradeCfg config.UpgradeConfig,
+) (*ClientRecord, error) {
+	logger := r.logger.With(
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("previous_chain_id", previousChainID),
+		zap.String("previous_client_id", previous.ClientID),
+	)
+	upgrade := ClientUpgrade{
+		PreviousChainID:  previousChainID,
+		PreviousClientID: previous.ClientID,
+		ChainID:          dst.ChainID(),
+	}
+
+	var record *ClientRecord
+	if len(upgradeCfg.PreviousRPCAddr) > 0 {
+		planHeight, err := r.upgradeClient(ctx, src, dst, previous.ClientID, previousChainID, upgradeCfg.PreviousRPCAddr, policy)
+		if err == nil {
+			if record, err = r.newClientRecord(ctx, src, previous.ClientID, previous.CreatedHeight); err != nil {
+				return nil, err
+			}
+			record.CreatedTime = previous.CreatedTime
+			record.Substitutions = previous.Substitutions
+			upgrade.Method = UpgradeMethodMsgUpgradeClient
+			upgrade.PlanHeight = planHeight
+		} else {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			logger.Warn("Failed to upgrade the client of the previous revision. Create a new client instead", zap.Error(err))
+		}
+	}
+	if record == nil {
+		var err error
+		if record, err = r.createNewClient(ctx, src, dst, srch, dsth, policy, clientCfg); err != nil {
+			return nil, err
+		}
+		upgrade.Method = UpgradeMethodNewClient
+	}
+	upgrade.ClientID = record.ClientID
+	upgrade.Time = time.Now().UTC()
+	record.Upgrades = append(append([]ClientUpgrade{}, previous.Upgrades...), upgrade)
+
+	if err := r.clientStore.Set(src.ChainID(), dst.ChainID(), record); err != nil {
+		return nil, fmt.Errorf("error writing client %s for chain %s to DB: %w", record.ClientID, dst.ChainID(), err)
+	}
+	// the lineage is kept in the new record
+	if err := r.clientStore.Delete(src.ChainID(), previousChainID); err != nil {
+		logger.Warn("failed to delete the client record of the previous revision from DB", zap.Error(err))
+	}
+
+	logger.Info(
+		"Followed the CZ to its new revision",
+		zap.String("dst_client_id", record.ClientID),
+		zap.String("method", upgrade.Method),
+		zap.Int64("plan_height", upgrade.PlanHeight),
+	)
+	return record, nil
+}
+
+// followRevisionUpgrade follows dst chain to the given new revision that its
+// node serves, from the client of its current revision on src chain as in
+// followUpgrade, and returns the chain of the new revision to be relayed
+// instead of dst chain
+func (r *Relayer) followRevisionUpgrade(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	newChainID string,
+	czCfg *config.CZConfig,
+) (*relayer.Chain, error) {
+	newDst, err := r.revisionChain(ctx, dst, newChainID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := r.clientStore.Get(src.ChainID(), dst.ChainID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client of chain %s on chain %s: %w", dst.ChainID(), src.ChainID(), err)
+	}
+	srch, dsth, err := r.queryCommittedHeights(ctx, src, newDst, czCfg.RelayPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.followUpgrade(ctx, src, newDst, dst.ChainID(), previous, srch, dsth, czCfg.RelayPolicy, czCfg.Client, czCfg.Upgrade); err != nil {
+		return nil, err
+	}
+
+	// NOTE: other components, e.g., the misbehaviour watcher, keep using the
+	// chain ID in config until restart
+	r.logger.Warn(
+		"Followed the CZ to the new revision served by its node. Relaying the new revision, update its chain ID in config",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("new_chain_id", newChainID),
+	)
+	return newDst, nil
+}
+
+// upgradeClient upgrades the given client on src chain that tracks the given
+// previous revision of dst chain to the new revision by a MsgUpgradeClient,
+// where the upgraded client and consensus states and their proofs are queried
+// from the given node of the previous revision. The client is first updated
+// to the height of the upgrade plan in the same tx if needed. It returns the
+// height of the upgrade plan.
+// (adapted from https://github.com/cosmos/relayer/blob/v2.4.2/relayer/client.go#L398)
+func (r *Relayer) upgradeClient(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	clientID string,
+	previousChainID string,
+	previousRPCAddr string,
+	policy config.RelayPolicy,
+) (int64, error) {
+	previous, err := r.revisionProvider(ctx, dst, previousChainID, previousRPCAddr)
+	if err != nil {
+		return 0, err
+	}
+
+	planRes, err := upgradetypes.NewQueryClient(previous).CurrentPlan(ctx, &upgradetypes.QueryCurrentPlanRequest{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query upgrade plan of chain %s: %w", previousChainID, err)
+	}
+	if planRes.Plan == nil {
+		return 0, fmt.Errorf("chain %s has no upgrade plan", previousChainID)
+	}
+	planHeight := planRes.Plan.Height
+
+	clientState, err := src.ChainProvider.QueryClientState(ctx, 0, clientID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query client state of client %s: %w", clientID, err)
+	}
+	tmClientState, ok := clientState.(*ibctm.ClientState)
+	if !ok {
+		return 0, fmt.Errorf("client %s is not a Tendermint client", clientID)
+	}
+	latestHeight := tmClientState.LatestHeight
+
+	// the upgraded states are committed under the plan height, which
+	// MsgUpgradeClient requires to be the latest height of the client
+	var msgs []provider.RelayerMessage
+	switch {
+	case latestHeight.RevisionHeight < uint64(planHeight):
+		header, err := previous.QueryIBCHeader(ctx, planHeight)
+		if err != nil {
+			return 0, fmt.Errorf("failed to query header at plan height %d: %w", planHeight, err)
+		}
+		trustedHeader, err := previous.QueryIBCHeader(ctx, int64(latestHeight.RevisionHeight)+1)
+		if err != nil {
+			return 0, fmt.Errorf("failed to query trusted header at height %d: %w", latestHeight.RevisionHeight+1, err)
+		}
+		updateHeader, err := previous.MsgUpdateClientHeader(header, latestHeight, trustedHeader)
+		if err != nil {
+			return 0, newUpdateClientError(ErrClassHeaderVerification, err)
+		}
+		updateMsg, err := src.ChainProvider.MsgUpdateClient(clientID, updateHeader)
+		if err != nil {
+			return 0, err
+		}
+		msgs = append(msgs, updateMsg)
+	case latestHeight.RevisionHeight > uint64(planHeight):
+		return 0, fmt.Errorf("latest height %s of client %s is beyond the plan height %d", latestHeight, clientID, planHeight)
+	}
+
+	clientRes, err := previous.QueryUpgradedClient(ctx, planHeight)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query upgraded client state: %w", err)
+	}
+	consRes, err := previous.QueryUpgradedConsState(ctx, planHeight)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query upgraded consensus state: %w", err)
+	}
+	upgradeMsg := &clienttypes.MsgUpgradeClient{
+		ClientId:                   clientID,
+		ClientState:                clientRes.ClientState,
+		ConsensusState:             consRes.ConsensusState,
+		ProofUpgradeClient:         clientRes.Proof,
+		ProofUpgradeConsensusState: consRes.Proof,
+	}
+	msgs = append(msgs, cosmos.NewCosmosMessage(upgradeMsg, func(signer string) {
+		upgradeMsg.Signer = signer
+	}))
+
+	sendCtx, cancel := r.sendContext(ctx)
+	defer cancel()
+	dstChainIDs := make([]string, len(msgs))
+	for i := range dstChainIDs {
+		dstChainIDs[i] = dst.ChainID()
+	}
+	if _, err := r.sendMsgs(sendCtx, src, r.pickKey(src, dst.ChainID()), dstChainIDs, msgs, policy); err != nil {
+		return 0, wrapUpdateClientError(err)
+	}
+
+	r.logger.Info(
+		"Upgraded the client to the new revision",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("dst_client_id", clientID),
+		zap.Int64("plan_height", planHeight),
+	)
+	return planHeight, nil
+}
+
+// revisionChain returns the chain of the given revision of dst chain served
+// by the node of dst chain
+func (r *Relayer) revisionChain(ctx context.Context, dst *relayer.Chain, chainID string) (*relayer.Chain, error) {
+	dcc, err := cosmosProvider(dst)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.revisionProvider(ctx, dst, chainID, dcc.PCfg.RPCAddr)
+	if err != nil {
+		return nil, err
+	}
+	return relayer.NewChain(r.logger, p, false), nil
+}
+
+// revisionProvider returns a provider of the given revision of dst chain at
+// the given RPC address, which shares the config of dst chain otherwise
+func (r *Relayer) revisionProvider(
+	ctx context.Context,
+	dst *relayer.Chain,
+	chainID string,
+	rpcAddr string,
+) (*cosmos.CosmosProvider, error) {
+	dcc, err := cosmosProvider(dst)
+	if err != nil {
+		return nil, err
+	}
+	pcfg := dcc.PCfg
+	pcfg.ChainID = chainID
+	pcfg.RPCAddr = rpcAddr
+	p, err := pcfg.NewProvider(r.logger, r.homePath, false, dcc.PCfg.ChainName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider of chain %s: %w", chainID, err)
+	}
+	if err := p.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialise provider of chain %s: %w", chainID, err)
+	}
+	return p.(*cosmos.CosmosProvider), nil
+}
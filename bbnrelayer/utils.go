@@ -2,11 +2,15 @@ package bbnrelayer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
 	"time"
 
 	"github.com/avast/retry-go/v4"
+	"github.com/babylonchain/babylon-relayer/config"
+	clienttypes "github.com/cosmos/ibc-go/v8/modules/core/02-client/types" //nolint:staticcheck
+	ibctm "github.com/cosmos/ibc-go/v8/modules/light-clients/07-tendermint"
 	"github.com/cosmos/relayer/v2/relayer"
 	"github.com/cosmos/relayer/v2/relayer/provider"
 	"github.com/juju/fslock"
@@ -14,151 +18,424 @@ import (
 )
 
 const (
-	// constants for creating a light client
-	trustingPeriodPercentage     = 85 // TrustingPeriodPercentage * UnbondingPeriod = TrustingPeriod
-	allowUpdateAfterExpiry       = true
-	allowUpdateAfterMisbehaviour = true
-	override                     = true
+	// default parameters for creating a light client, unless specified for the CZ
+	defaultTrustingPeriodPercentage     = 85 // TrustingPeriodPercentage * UnbondingPeriod = TrustingPeriod
+	defaultMaxClockDrift                = 10 * time.Minute
+	defaultTrustLevel                   = "1/3"
+	defaultAllowUpdateAfterExpiry       = true
+	defaultAllowUpdateAfterMisbehaviour = true
+	defaultOverride                     = true
 )
 
 // createClientIfNotExist ensures that the dst light client exists on src chain
-// if does not exist, the function will create a new dst light client on src chain
+// if no client is stored for dst chain, the function will create a new dst light
+// client on src chain. A stored client is never replaced here, since its record
+// holds the lineage of the client.
 func (r *Relayer) createClientIfNotExist(
 	ctx context.Context,
 	src *relayer.Chain,
 	dst *relayer.Chain,
-	numRetries uint,
+	policy config.RelayPolicy,
+	clientCfg config.ClientConfig,
+	upgradeCfg config.UpgradeConfig,
 ) error {
 	// query the latest heights on src and dst
-	// retry here in case the CZ endpoint becomes unstable
-	var srch, dsth int64
-	if err := retry.Do(func() error {
-		var err error
-		srch, dsth, err = relayer.QueryLatestHeights(ctx, src, dst)
-		if err != nil {
-			return fmt.Errorf("failed to query latest heights: %w", err)
+	srch, dsth, err := r.queryCommittedHeights(ctx, src, dst, policy)
+	if err != nil {
+		return err
+	}
+
+	// check whether the dst light client exists on src at the latest height
+	// if exists and queryable, return directly
+	record, err := r.clientStore.Get(src.ChainID(), dst.ChainID())
+	if err != nil && !errors.Is(err, ErrClientNotFound) {
+		return err
+	}
+	if err == nil {
+		if err := r.checkStoredClient(ctx, src, dst, srch, record); err != nil {
+			return err
 		}
-		return nil
-	}, retry.Context(ctx), retry.Attempts(numRetries), relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
 		r.logger.Info(
-			"Failed to query latest heights",
+			"the light client already exists. Skip creating the light client.",
 			zap.String("src_chain_id", src.ChainID()),
 			zap.String("dst_chain_id", dst.ChainID()),
-			zap.Uint("attempt", n+1),
-			zap.Uint("max_attempts", numRetries),
-			zap.Error(err),
+			zap.String("dst_client_id", record.ClientID),
 		)
-	})); err != nil {
+		return nil
+	}
+
+	// a record written before namespacing may belong to any Babylon network,
+	// so it is adopted by src chain only if its client exists on src chain
+	// and tracks dst chain
+	if unscoped, err := r.clientStore.Get("", dst.ChainID()); err == nil {
+		if err := r.checkStoredClient(ctx, src, dst, srch, unscoped); err != nil {
+			if ClassifyError(err) == ErrClassClientMismatch {
+				return newUpdateClientError(ErrClassClientMismatch, fmt.Errorf(
+					"client %s of chain %s is stored without a Babylon chain ID and does not belong to chain %s, move it under its Babylon chain with `clients migrate` or delete it: %w",
+					unscoped.ClientID, dst.ChainID(), src.ChainID(), err,
+				))
+			}
+			return err
+		}
+		return r.adoptUnscopedClient(src, dst, unscoped)
+	} else if !errors.Is(err, ErrClientNotFound) {
 		return err
 	}
-	// in case block at srch/dsth has not been committed yet
-	// see https://github.com/tendermint/tendermint/issues/7641
-	srch--
-	dsth--
 
-	// check whether the dst light client exists on src at the latest height
-	// if exists and queryable, return directly
-	clientID, err := r.getClientID(dst.ChainID())
+	// the CZ may have been upgraded to a new revision, i.e., chain ID, since
+	// the client of its previous revision was created
+	previousChainID, previous, err := r.previousRevisionRecord(src.ChainID(), dst.ChainID())
 	if err != nil {
 		return err
 	}
-	if len(clientID) > 0 {
-		if _, err := src.ChainProvider.QueryClientState(ctx, srch, clientID); err == nil {
-			r.logger.Info(
-				"the light client already exists. Skip creating the light client.",
-				zap.String("src_chain_id", src.ChainID()),
-				zap.String("dst_chain_id", dst.ChainID()),
-				zap.String("dst_client_id", clientID),
-			)
-			return nil
-		}
+	if previous != nil {
+		_, err := r.followUpgrade(ctx, src, dst, previousChainID, previous, srch, dsth, policy, clientCfg, upgradeCfg)
+		return err
 	}
 
-	// if the code reaches here, then it means the client does not exist
+	// if the code reaches here, then it means no client is stored
 	// we need to create a new one
 	r.logger.Info(
 		"the light client does not exist. Creating a new light client.",
 		zap.String("src_chain_id", src.ChainID()),
 		zap.String("dst_chain_id", dst.ChainID()),
 	)
+	record, err = r.createNewClient(ctx, src, dst, srch, dsth, policy, clientCfg)
+	if err != nil {
+		return err
+	}
+
+	// the client is now created and queryable
+	// writes the record of this client to DB
+	if err := r.clientStore.Set(src.ChainID(), dst.ChainID(), record); err != nil {
+		return fmt.Errorf("error writing clientID %s for chain %s to DB: %w", record.ClientID, dst.ChainID(), err)
+	}
+
+	r.logger.Info(
+		"successfully inserted the light client ID to DB",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("dst_client_id", record.ClientID),
+	)
+
+	return nil
+}
+
+// checkStoredClient checks that the client of the given record exists on src
+// chain at height srch and tracks dst chain. The stored client might belong
+// to another Babylon network, in which case ErrClassClientMismatch is
+// returned, and the client is neither reused nor overwritten.
+func (r *Relayer) checkStoredClient(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	srch int64,
+	record *ClientRecord,
+) error {
+	clientState, err := src.ChainProvider.QueryClientState(ctx, srch, record.ClientID)
+	if err != nil {
+		// no client with the same ID exists on another Babylon network
+		if errorMatches(err, clienttypes.ErrClientNotFound) {
+			return newUpdateClientError(ErrClassClientMismatch, fmt.Errorf(
+				"stored client %s does not exist on chain %s: %w", record.ClientID, src.ChainID(), err,
+			))
+		}
+		return newUpdateClientError(ErrClassTransient, fmt.Errorf(
+			"failed to query state of stored client %s on chain %s: %w", record.ClientID, src.ChainID(), err,
+		))
+	}
+	// the client with the same ID on another Babylon network tracks another chain
+	if tmClientState, ok := clientState.(*ibctm.ClientState); ok && tmClientState.ChainId != dst.ChainID() {
+		return newUpdateClientError(ErrClassClientMismatch, fmt.Errorf(
+			"stored client %s on chain %s tracks chain %s rather than chain %s",
+			record.ClientID, src.ChainID(), tmClientState.ChainId, dst.ChainID(),
+		))
+	}
+	return nil
+}
+
+// adoptUnscopedClient moves the given record of dst chain stored before
+// namespacing under src chain, as `clients migrate` does
+func (r *Relayer) adoptUnscopedClient(src *relayer.Chain, dst *relayer.Chain, record *ClientRecord) error {
+	record.BabylonChainID = src.ChainID()
+	if err := r.clientStore.Set(src.ChainID(), dst.ChainID(), record); err != nil {
+		return fmt.Errorf("error writing clientID %s for chain %s to DB: %w", record.ClientID, dst.ChainID(), err)
+	}
+	if err := r.clientStore.Delete("", dst.ChainID()); err != nil {
+		return fmt.Errorf("error deleting unscoped clientID %s for chain %s from DB: %w", record.ClientID, dst.ChainID(), err)
+	}
+	r.logger.Info(
+		"adopted the light client stored without a Babylon chain ID",
+		zap.String("src_chain_id", src.ChainID()),
+		zap.String("dst_chain_id", dst.ChainID()),
+		zap.String("dst_client_id", record.ClientID),
+	)
+	return nil
+}
 
-	// Query the light signed headers for src & dst at the heights srch & dsth
-	var srcUpdateHeader, dstUpdateHeader provider.IBCHeader
+// queryCommittedHeights queries the latest heights on src and dst chains, and
+// returns the heights before them, which have been committed for sure
+func (r *Relayer) queryCommittedHeights(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	policy config.RelayPolicy,
+) (int64, int64, error) {
+	// retry here in case the CZ endpoint becomes unstable
+	var srch, dsth int64
 	if err := retry.Do(func() error {
 		var err error
-		srcUpdateHeader, dstUpdateHeader, err = relayer.QueryIBCHeaders(ctx, src, dst, srch, dsth)
+		srch, dsth, err = relayer.QueryLatestHeights(ctx, src, dst)
 		if err != nil {
-			return fmt.Errorf("failed to query update headers: %w", err)
+			return fmt.Errorf("failed to query latest heights: %w", err)
 		}
 		return nil
-	}, retry.Context(ctx), retry.Attempts(numRetries), relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
 		r.logger.Info(
-			"Failed to query update headers",
+			"Failed to query latest heights",
 			zap.String("src_chain_id", src.ChainID()),
 			zap.String("dst_chain_id", dst.ChainID()),
 			zap.Uint("attempt", n+1),
-			zap.Uint("max_attempts", numRetries),
+			zap.Uint("max_attempts", policy.Retries),
 			zap.Error(err),
 		)
 	})); err != nil {
-		return err
+		return 0, 0, err
 	}
+	// in case block at srch/dsth has not been committed yet
+	// see https://github.com/tendermint/tendermint/issues/7641
+	return srch - 1, dsth - 1, nil
+}
 
-	// `relayer.CreateClient` will access the PathEnd of src chain
-	// since we don't require phase1 integration to set up paths,
-	// we need to create empty PathEnd here to prevent nil pointer error
-	if src.PathEnd == nil {
-		src.PathEnd = &relayer.PathEnd{}
-	}
-	// create the client on src chain, where we use default values for some fields
-	krErr := r.accessKeyWithLock(func() {
-		clientID, err = relayer.CreateClient(
-			ctx,
-			src,
-			dst,
-			srcUpdateHeader,
-			dstUpdateHeader,
-			allowUpdateAfterExpiry,
-			allowUpdateAfterMisbehaviour,
-			override,
-			0, // relayer will calculate the trusting period based on unbonding period if this is 0
-			0, // relayer will query the unbonding period if this is 0
-			r.cfg.Global.Memo,
+// createNewClient creates a new light client of dst chain on src chain from
+// the header of dst chain at height dsth, waits until it is queryable, and
+// returns its record, which is not written to DB yet
+func (r *Relayer) createNewClient(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	srch int64,
+	dsth int64,
+	policy config.RelayPolicy,
+	clientCfg config.ClientConfig,
+) (*ClientRecord, error) {
+	// Query the light signed header for dst at the height dsth
+	var dstUpdateHeader provider.IBCHeader
+	if err := retry.Do(func() error {
+		var err error
+		dstUpdateHeader, err = dst.ChainProvider.QueryIBCHeader(ctx, dsth)
+		if err != nil {
+			return fmt.Errorf("failed to query update header: %w", err)
+		}
+		return nil
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+		r.logger.Info(
+			"Failed to query update header",
+			zap.String("src_chain_id", src.ChainID()),
+			zap.String("dst_chain_id", dst.ChainID()),
+			zap.Uint("attempt", n+1),
+			zap.Uint("max_attempts", policy.Retries),
+			zap.Error(err),
 		)
-	})
-	if krErr != nil {
-		return krErr
+	})); err != nil {
+		return nil, err
 	}
+
+	// create the client on src chain, where we use default values for some fields
+	// the tx is allowed to finish within the grace period upon shutdown
+	sendCtx, cancelSend := r.sendContext(ctx)
+	defer cancelSend()
+	clientID, err := r.createClient(sendCtx, src, dst, dstUpdateHeader, policy, clientCfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	r.logger.Info(
 		"successfully created the light client",
 		zap.String("src_chain_id", src.ChainID()),
 		zap.String("dst_chain_id", dst.ChainID()),
-		zap.String("dst_client_id", src.ClientID()),
+		zap.String("dst_client_id", clientID),
 	)
 
 	// wait until client is queryable on chain
-	if err := r.waitUntilQuerable(ctx, src, dst, numRetries); err != nil {
-		return err
+	// this is also done within the grace period upon shutdown, so that the
+	// client ID of the created client is not lost
+	if err := r.waitUntilQuerable(sendCtx, src, dst, clientID, policy); err != nil {
+		return nil, err
 	}
+	return r.newClientRecord(sendCtx, src, clientID, srch)
+}
 
-	// the client is now created and queryable
-	// writes the config with this client ID to DB
-	if err := r.setClientID(dst.ChainID(), clientID); err != nil {
-		return fmt.Errorf("error writing clientID %s for chain %s to DB: %w", clientID, dst.ChainID(), err)
+// createClient creates a light client of dst chain on src chain from the given
+// header of dst chain, and returns the ID of the client.
+// same as https://github.com/cosmos/relayer/blob/v2.4.2/relayer/client.go
+// except for checking the trusting period against the actual unbonding period
+// of dst chain, and sending the tx with the key pool and grants of src chain
+func (r *Relayer) createClient(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	dstHeader provider.IBCHeader,
+	policy config.RelayPolicy,
+	clientCfg config.ClientConfig,
+) (string, error) {
+	// the actual unbonding period of dst chain is always queried, and is used
+	// unless another one is specified
+	var actualUbdPeriod time.Duration
+	if err := retry.Do(func() error {
+		var err error
+		actualUbdPeriod, err = dst.ChainProvider.QueryUnbondingPeriod(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query unbonding period of chain %s: %w", dst.ChainID(), err)
+		}
+		return nil
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr); err != nil {
+		return "", err
+	}
+	ubdPeriod := clientCfg.UnbondingPeriod
+	if ubdPeriod == 0 {
+		ubdPeriod = actualUbdPeriod
+	}
+	// the relayer calculates the trusting period from the unbonding period if it
+	// is not specified, where the trusting period is in whole hours as upstream
+	trustingPeriod := clientCfg.TrustingPeriod
+	if trustingPeriod == 0 {
+		percentage := clientCfg.TrustingPeriodPercentage
+		if percentage == 0 {
+			percentage = defaultTrustingPeriodPercentage
+		}
+		trustingPeriod = ubdPeriod / 100 * time.Duration(percentage)
+		if trustingPeriod > time.Hour {
+			trustingPeriod = trustingPeriod.Truncate(time.Hour)
+		}
+	}
+	if trustingPeriod >= ubdPeriod {
+		return "", fmt.Errorf("trusting period %v is not shorter than unbonding period %v of chain %s", trustingPeriod, ubdPeriod, dst.ChainID())
+	}
+	if trustingPeriod >= actualUbdPeriod {
+		return "", fmt.Errorf("trusting period %v is not shorter than the actual unbonding period %v of chain %s", trustingPeriod, actualUbdPeriod, dst.ChainID())
 	}
 
-	r.logger.Info(
-		"successfully inserted the light client ID to DB",
-		zap.String("src_chain_id", src.ChainID()),
-		zap.String("dst_chain_id", dst.ChainID()),
-		zap.String("dst_client_id", src.ClientID()),
+	maxClockDrift := clientCfg.MaxClockDrift
+	if maxClockDrift == 0 {
+		maxClockDrift = defaultMaxClockDrift
+	}
+	trustLevel := clientCfg.TrustLevel
+	if len(trustLevel) == 0 {
+		trustLevel = defaultTrustLevel
+	}
+	trustLevelNum, trustLevelDen, err := config.ParseTrustLevel(trustLevel)
+	if err != nil {
+		return "", err
+	}
+	allowUpdateAfterExpiry := defaultAllowUpdateAfterExpiry
+	if clientCfg.AllowUpdateAfterExpiry != nil {
+		allowUpdateAfterExpiry = *clientCfg.AllowUpdateAfterExpiry
+	}
+	allowUpdateAfterMisbehaviour := defaultAllowUpdateAfterMisbehaviour
+	if clientCfg.AllowUpdateAfterMisbehaviour != nil {
+		allowUpdateAfterMisbehaviour = *clientCfg.AllowUpdateAfterMisbehaviour
+	}
+	override := defaultOverride
+	if clientCfg.Override != nil {
+		override = *clientCfg.Override
+	}
+
+	clientState, err := dst.ChainProvider.NewClientState(
+		dst.ChainID(),
+		dstHeader,
+		trustingPeriod,
+		ubdPeriod,
+		allowUpdateAfterExpiry,
+		allowUpdateAfterMisbehaviour,
 	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create new client state of chain %s: %w", dst.ChainID(), err)
+	}
+	// the provider hard-codes the max clock drift and the trust level
+	tmClientState, ok := clientState.(*ibctm.ClientState)
+	if !ok {
+		return "", fmt.Errorf("client state of chain %s is not a Tendermint client state", dst.ChainID())
+	}
+	tmClientState.MaxClockDrift = maxClockDrift
+	tmClientState.TrustLevel = ibctm.Fraction{Numerator: trustLevelNum, Denominator: trustLevelDen}
+	if err := tmClientState.Validate(); err != nil {
+		return "", fmt.Errorf("invalid client state of chain %s: %w", dst.ChainID(), err)
+	}
 
-	return nil
+	// reuse an active client with the same state, unless overridden
+	if !override {
+		clientID, err := r.findMatchingClient(ctx, src, dst, tmClientState, policy)
+		if err != nil {
+			return "", err
+		}
+		if len(clientID) > 0 {
+			r.logger.Info(
+				"Found an existing client matching the client to be created, reuse it",
+				zap.String("src_chain_id", src.ChainID()),
+				zap.String("dst_chain_id", dst.ChainID()),
+				zap.String("dst_client_id", clientID),
+			)
+			return clientID, nil
+		}
+	}
+
+	msg, err := src.ChainProvider.MsgCreateClient(clientState, dstHeader.ConsensusState())
+	if err != nil {
+		return "", fmt.Errorf("failed to compose MsgCreateClient of chain %s: %w", dst.ChainID(), err)
+	}
+
+	txResp, err := r.sendMsgs(ctx, src, r.pickKey(src, dst.ChainID()), []string{dst.ChainID()}, []provider.RelayerMessage{msg}, policy)
+	if err != nil {
+		return "", err
+	}
+	for _, event := range txResp.Events {
+		if event.EventType != clienttypes.EventTypeCreateClient {
+			continue
+		}
+		if clientID, ok := event.Attributes[clienttypes.AttributeKeyClientID]; ok {
+			return clientID, nil
+		}
+	}
+	return "", fmt.Errorf("client ID not found in the events of tx %s", txResp.TxHash)
+}
+
+// findMatchingClient returns the ID of an active client of dst chain on src
+// chain whose state matches the given client state, or an empty ID if there is none
+// same as https://github.com/cosmos/relayer/blob/v2.4.2/relayer/client.go
+// except for skipping expired clients rather than failing on them
+func (r *Relayer) findMatchingClient(
+	ctx context.Context,
+	src *relayer.Chain,
+	dst *relayer.Chain,
+	clientState *ibctm.ClientState,
+	policy config.RelayPolicy,
+) (string, error) {
+	var clients clienttypes.IdentifiedClientStates
+	if err := retry.Do(func() error {
+		var err error
+		clients, err = src.ChainProvider.QueryClients(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query clients on chain %s: %w", src.ChainID(), err)
+		}
+		return nil
+	}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr); err != nil {
+		return "", err
+	}
+
+	for _, client := range clients {
+		clientID, err := provider.ClientsMatch(ctx, src.ChainProvider, dst.ChainProvider, client, clientState)
+		if err != nil && errorMatches(err, ibctm.ErrTrustingPeriodExpired) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to match client %s on chain %s: %w", client.ClientId, src.ChainID(), err)
+		}
+		if len(clientID) > 0 {
+			return clientID, nil
+		}
+	}
+	return "", nil
 }
 
 // waitUntilQuerable asks the relayer to wait until the dst light client is queryable on src chain
@@ -166,11 +443,19 @@ func (r *Relayer) waitUntilQuerable(
 	ctx context.Context,
 	src *relayer.Chain,
 	dst *relayer.Chain,
-	numRetries uint,
+	clientID string,
+	policy config.RelayPolicy,
 ) error {
 	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
 
-	for range ticker.C {
 		// query the latest heights on src and dst
 		// retry here in case the CZ endpoint becomes unstable
 		var srch, dsth int64
@@ -181,13 +466,13 @@ func (r *Relayer) waitUntilQuerable(
 				return fmt.Errorf("failed to query latest heights: %w", err)
 			}
 			return nil
-		}, retry.Context(ctx), retry.Attempts(numRetries), relayer.RtyDel, relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
+		}, retry.Context(ctx), retry.Attempts(policy.Retries), retry.Delay(policy.RetryDelay), relayer.RtyErr, retry.OnRetry(func(n uint, err error) {
 			r.logger.Info(
 				"Failed to query latest heights",
 				zap.String("src_chain_id", src.ChainID()),
 				zap.String("dst_chain_id", dst.ChainID()),
 				zap.Uint("attempt", n+1),
-				zap.Uint("max_attempts", numRetries),
+				zap.Uint("max_attempts", policy.Retries),
 				zap.Error(err),
 			)
 		})); err != nil {
@@ -199,31 +484,46 @@ func (r *Relayer) waitUntilQuerable(
 		srch--
 		dsth--
 
-		if _, err := src.ChainProvider.QueryClientState(ctx, srch, src.ClientID()); err == nil {
+		if _, err := src.ChainProvider.QueryClientState(ctx, srch, clientID); err == nil {
 			r.logger.Info(
 				"the light client becomes committed on-chain, complete creating the light client",
 				zap.String("src_chain_id", src.ChainID()),
 				zap.String("dst_chain_id", dst.ChainID()),
-				zap.String("dst_client_id", src.ClientID()),
+				zap.String("dst_client_id", clientID),
 			)
 
-			break
+			return nil
 		}
 
 		r.logger.Info(
 			"the light client has not been committed on-chain yet, keep waiting",
 			zap.String("src_chain_id", src.ChainID()),
 			zap.String("dst_chain_id", dst.ChainID()),
-			zap.String("dst_client_id", src.ClientID()),
+			zap.String("dst_client_id", clientID),
 		)
 	}
+}
 
-	return nil
+// sendContext returns a context for sending txs that is not cancelled
+// immediately with ctx, but only after the shutdown grace period has passed
+// since then. This allows in-flight txs to be included upon shutdown.
+func (r *Relayer) sendContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	sendCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(r.shutdownGracePeriod, cancel)
+	})
+	return sendCtx, func() {
+		stop()
+		cancel()
+	}
 }
 
 // accessKeyWithLock triggers a function that access key ring while acquiring
 // the file system lock, in order to remain thread-safe when multiple concurrent
 // relayers are running on the same machine and accessing the same keyring
+// NOTE: txs only hold the lock while being built and broadcast, rather than
+// until they are included, and txs signed by the same key are ordered in
+// memory by the sequenceManager of the key
 func (r *Relayer) accessKeyWithLock(accessFunc func()) error {
 	// use lock file to guard concurrent access to the keyring
 	lockFilePath := path.Join(r.homePath, "keys", "keys.lock")
@@ -242,3 +542,31 @@ func (r *Relayer) accessKeyWithLock(accessFunc func()) error {
 
 	return nil
 }
+
+// refreshAccount queries the account numbers and sequences of the keys that
+// sign client updates sent to the given chain after an account sequence
+// mismatch, and resyncs their sequence managers so that the next txs are
+// built upon the account states queried here.
+func (r *Relayer) refreshAccount(ctx context.Context, chain *relayer.Chain) error {
+	cc, err := cosmosProvider(chain)
+	if err != nil {
+		return err
+	}
+	for _, key := range r.signingKeys(chain) {
+		accNum, seq, err := queryAccount(ctx, cc, key)
+		if err != nil {
+			return err
+		}
+		r.sequenceManager(chain.ChainID(), key).reset(seq)
+
+		r.logger.Info(
+			"refreshed account state",
+			zap.String("src_chain_id", chain.ChainID()),
+			zap.String("key", key),
+			zap.Uint64("account_number", accNum),
+			zap.Uint64("sequence", seq),
+		)
+	}
+
+	return nil
+}
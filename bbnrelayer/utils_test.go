@@ -0,0 +1,69 @@
+package bbnrelayer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/config"
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	"github.com/cosmos/relayer/v2/relayer"
+	"go.uber.org/zap"
+)
+
+func TestCreateClientIfNotExistUnscopedRecord(t *testing.T) {
+	policy := config.RelayPolicy{Retries: 1, RetryDelay: time.Millisecond}
+	tests := []struct {
+		name         string
+		dstChainID   string
+		noClient     bool
+		wantAdoption bool
+	}{
+		{
+			name:         "client tracks the CZ",
+			dstChainID:   testCZChainID,
+			wantAdoption: true,
+		},
+		{
+			name:       "client tracks another chain",
+			dstChainID: "otherchain",
+		},
+		{
+			name:       "client does not exist",
+			dstChainID: testCZChainID,
+			noClient:   true,
+		},
+	}
+	for _, tt := range tests {
+		src := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: testBabylonChainID, height: 100, noClient: tt.noClient}, false)
+		dst := relayer.NewChain(zap.NewNop(), &fakeProvider{chainID: tt.dstChainID, height: 11}, false)
+		clientStore := NewMemClientStore()
+		if err := clientStore.Set("", tt.dstChainID, &ClientRecord{ClientID: testClientID}); err != nil {
+			t.Fatal(err)
+		}
+		r := New("", nil, zap.NewNop(), relaydebug.NewPrometheusMetrics(), clientStore, NewMemCostStore(), 0)
+
+		err := r.createClientIfNotExist(context.Background(), src, dst, policy, config.ClientConfig{}, config.UpgradeConfig{})
+		_, unscopedErr := clientStore.Get("", tt.dstChainID)
+		record, scopedErr := clientStore.Get(testBabylonChainID, tt.dstChainID)
+		if tt.wantAdoption {
+			if err != nil {
+				t.Errorf("%s: got error %v, expected the record to be adopted", tt.name, err)
+			}
+			if scopedErr != nil || record.ClientID != testClientID || record.BabylonChainID != testBabylonChainID {
+				t.Errorf("%s: got record %+v with error %v under chain %s, expected the adopted record", tt.name, record, scopedErr, testBabylonChainID)
+			}
+			if !errors.Is(unscopedErr, ErrClientNotFound) {
+				t.Errorf("%s: the unscoped record is kept after adoption", tt.name)
+			}
+			continue
+		}
+		if got := ClassifyError(err); err == nil || got != ErrClassClientMismatch {
+			t.Errorf("%s: got error %v, expected an error of class %s", tt.name, err, ErrClassClientMismatch)
+		}
+		if unscopedErr != nil || !errors.Is(scopedErr, ErrClientNotFound) {
+			t.Errorf("%s: the refused record is moved", tt.name)
+		}
+	}
+}
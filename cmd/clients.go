@@ -0,0 +1,537 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/bbnrelayer"
+	"github.com/babylonchain/babylon-relayer/config"
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	"github.com/spf13/cobra"
+)
+
+// clientsCmd is the command group for inspecting and editing the client store
+func clientsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clients",
+		Short: "inspect and edit the CZ light clients stored by the relayer",
+		Long: `Inspect and edit the CZ light clients stored by the relayer in its DB.
+The relayer stores the client ID of each CZ light client on Babylon so that
+it does not create another light client upon restart.`,
+	}
+
+	cmd.AddCommand(
+		clientsListCmd(),
+		clientsShowCmd(),
+		clientsStatusCmd(),
+		clientsSetCmd(),
+		clientsDeleteCmd(),
+		clientsResumeCmd(),
+		clientsExportCmd(),
+		clientsImportCmd(),
+		clientsMigrateCmd(),
+	)
+
+	return cmd
+}
+
+// openClientStore opens the client store under the home path specified in the flags of cmd
+func openClientStore(cmd *cobra.Command) (bbnrelayer.ClientStore, error) {
+	homePath, err := cmd.Flags().GetString("home")
+	if err != nil {
+		return nil, err
+	}
+	return bbnrelayer.NewLevelDBClientStore(config.GetDBPath(homePath))
+}
+
+// openReadOnlyClientStore opens the client store under the home path specified
+// in the flags of cmd for reading. While a relayer holds the DB, the records are
+// read from its status API at --relayer-addr instead.
+func openReadOnlyClientStore(cmd *cobra.Command) (bbnrelayer.ClientStore, error) {
+	homePath, err := cmd.Flags().GetString("home")
+	if err != nil {
+		return nil, err
+	}
+	relayerAddr, err := cmd.Flags().GetString("relayer-addr")
+	if err != nil {
+		return nil, err
+	}
+	store, err := bbnrelayer.NewReadOnlyLevelDBClientStore(config.GetDBPath(homePath))
+	if err == nil {
+		return store, nil
+	}
+	if len(relayerAddr) == 0 {
+		return nil, fmt.Errorf("%w. If the relayer is running, pass its --debug-addr as --relayer-addr", err)
+	}
+	return bbnrelayer.NewAPIClientStore(relayerAddr), nil
+}
+
+// printJSON prints the given object as indented JSON to the output of cmd
+func printJSON(cmd *cobra.Command, obj interface{}) error {
+	bz, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(bz))
+	return nil
+}
+
+func clientsListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"l"},
+		Short:   "list the CZ light clients stored by the relayer",
+		Args:    withUsage(cobra.ExactArgs(0)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients list`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			records, err := store.List()
+			if err != nil {
+				return err
+			}
+			keys := make([]bbnrelayer.ClientKey, 0, len(records))
+			for key := range records {
+				keys = append(keys, key)
+			}
+			sort.Slice(keys, func(i, j int) bool {
+				return keys[i].String() < keys[j].String()
+			})
+			for _, key := range keys {
+				record := records[key]
+				if len(record.Status) == 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", key, record.ClientID)
+				} else {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: %s (%s)\n", key, record.ClientID, record.Status)
+				}
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func clientsShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show babylon_chain_name cz_chain_id",
+		Short: "show the stored record and the on-chain status of the light client of cz_chain_id on babylon_chain_name",
+		Long: `Show the stored record of the light client of cz_chain_id, together with
+the client state, the latest consensus state and the status of the client
+queried from babylon_chain_name.`,
+		Args:    withUsage(cobra.ExactArgs(2)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients show babylon osmo-test-5`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			homePath, err := cmd.Flags().GetString("home")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadConfig(homePath, cmd)
+			if err != nil {
+				return err
+			}
+			babylonChain, ok := cfg.Chains[args[0]]
+			if !ok {
+				return fmt.Errorf("babylonChain %s not found in config. consider running `%s chains add %s`", args[0], AppName, args[0])
+			}
+
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			czChainID := args[1]
+			record, err := store.Get(babylonChain.ChainID(), czChainID)
+			if err != nil {
+				return fmt.Errorf("failed to get client of chain %s on chain %s: %w", czChainID, babylonChain.ChainID(), err)
+			}
+
+			// print the on-chain info next to the stored record
+			// if the client cannot be queried, print the reason instead
+			output := struct {
+				BabylonChainID string                   `json:"babylon_chain_id"`
+				ChainID        string                   `json:"chain_id"`
+				Record         *bbnrelayer.ClientRecord `json:"record"`
+				OnChain        *bbnrelayer.ClientInfo   `json:"on_chain,omitempty"`
+				OnChainError   string                   `json:"on_chain_error,omitempty"`
+			}{
+				BabylonChainID: babylonChain.ChainID(),
+				ChainID:        czChainID,
+				Record:         record,
+			}
+			output.OnChain, err = bbnrelayer.QueryClientInfo(cmd.Context(), babylonChain, record.ClientID)
+			if err != nil {
+				output.OnChainError = err.Error()
+			}
+
+			return printJSON(cmd, output)
+		},
+	}
+
+	return cmd
+}
+
+func clientsStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status babylon_chain_name",
+		Short: "show the status on babylon_chain_name of all light clients stored by the relayer",
+		Long: `Query the status of each light client of babylon_chain_name stored by the
+relayer, next to the status last seen by the relayer. Expired and Frozen clients
+are no longer updated by the relayer and require a recovery action, in which
+case the command exits with an error.
+While the relayer is running, it holds the DB, so the stored clients are read
+from its status API at --relayer-addr.`,
+		Args:    withUsage(cobra.ExactArgs(1)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients status babylon`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			homePath, err := cmd.Flags().GetString("home")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadConfig(homePath, cmd)
+			if err != nil {
+				return err
+			}
+			babylonChain, ok := cfg.Chains[args[0]]
+			if !ok {
+				return fmt.Errorf("babylonChain %s not found in config. consider running `%s chains add %s`", args[0], AppName, args[0])
+			}
+
+			store, err := openReadOnlyClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			records, err := store.List()
+			if err != nil {
+				return err
+			}
+
+			type clientStatus struct {
+				ChainID        string `json:"chain_id"`
+				ClientID       string `json:"client_id"`
+				Status         string `json:"status,omitempty"`
+				RecordedStatus string `json:"recorded_status,omitempty"`
+				Error          string `json:"error,omitempty"`
+			}
+			output := []clientStatus{}
+			inactive := 0
+			for key, record := range records {
+				if key.BabylonChainID != babylonChain.ChainID() {
+					continue
+				}
+				status := clientStatus{ChainID: key.CZChainID, ClientID: record.ClientID, RecordedStatus: record.Status}
+				s, err := bbnrelayer.QueryClientStatus(cmd.Context(), babylonChain, record.ClientID)
+				if err != nil {
+					status.Error = err.Error()
+				} else {
+					status.Status = s.String()
+				}
+				if s == ibcexported.Expired || s == ibcexported.Frozen {
+					inactive++
+				}
+				output = append(output, status)
+			}
+			sort.Slice(output, func(i, j int) bool {
+				return output[i].ChainID < output[j].ChainID
+			})
+
+			if err := printJSON(cmd, output); err != nil {
+				return err
+			}
+			if inactive > 0 {
+				return fmt.Errorf("%d of %d clients on chain %s are expired or frozen and require a recovery action", inactive, len(output), babylonChain.ChainID())
+			}
+			return nil
+		},
+	}
+
+	addRelayerAddrFlag(cmd)
+
+	return cmd
+}
+
+func clientsSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set babylon_chain_id cz_chain_id client_id",
+		Short: "set the client ID of the light client of cz_chain_id on babylon_chain_id",
+		Long: `Set the client ID of the light client of cz_chain_id on babylon_chain_id, so
+that the relayer keeps updating the given client rather than creating a new one.
+The metadata of an existing record is kept unless the client ID changes.`,
+		Args:    withUsage(cobra.ExactArgs(3)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients set bbn-test-3 osmo-test-5 07-tendermint-3`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			babylonChainID, czChainID, clientID := args[0], args[1], args[2]
+			record, err := store.Get(babylonChainID, czChainID)
+			if errors.Is(err, bbnrelayer.ErrClientNotFound) || (err == nil && record.ClientID != clientID) {
+				record = &bbnrelayer.ClientRecord{ClientID: clientID, BabylonChainID: babylonChainID}
+			} else if err != nil {
+				return err
+			}
+
+			return store.Set(babylonChainID, czChainID, record)
+		},
+	}
+
+	return cmd
+}
+
+func clientsDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "delete babylon_chain_id cz_chain_id",
+		Aliases: []string{"d"},
+		Short:   "delete the light client of cz_chain_id on babylon_chain_id from the DB",
+		Long: `Delete the light client of cz_chain_id on babylon_chain_id from the DB. The
+client on Babylon is not affected, but the relayer will create a new client upon restart.`,
+		Args:    withUsage(cobra.ExactArgs(2)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients delete bbn-test-3 osmo-test-5`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			babylonChainID, czChainID := args[0], args[1]
+			if _, err := store.Get(babylonChainID, czChainID); err != nil {
+				return fmt.Errorf("failed to get client of chain %s on chain %s: %w", czChainID, babylonChainID, err)
+			}
+			return store.Delete(babylonChainID, czChainID)
+		},
+	}
+
+	return cmd
+}
+
+func clientsResumeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resume babylon_chain_id cz_chain_id",
+		Short: "resume updating the light client of cz_chain_id on babylon_chain_id after it has been halted",
+		Long: `Resume updating the light client of cz_chain_id on babylon_chain_id after the
+relayer has halted it due to a consensus state that conflicts with a witness.
+Only resume a client once the conflict is found to be a false alarm, e.g.,
+caused by a faulty witness.`,
+		Args:    withUsage(cobra.ExactArgs(2)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients resume bbn-test-3 osmo-test-5`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			babylonChainID, czChainID := args[0], args[1]
+			return store.Update(babylonChainID, czChainID, func(record *bbnrelayer.ClientRecord) error {
+				if len(record.Halted) == 0 {
+					return fmt.Errorf("client %s of chain %s on chain %s is not halted", record.ClientID, czChainID, babylonChainID)
+				}
+				record.Halted = ""
+				record.HaltedTime = time.Time{}
+				return nil
+			})
+		},
+	}
+
+	return cmd
+}
+
+func clientsExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [file]",
+		Short: "export all light clients in the DB as JSON",
+		Long: `Export all light clients in the DB as JSON to the given file, or to stdout
+if no file is given. The output can be imported on another host with
+'clients import' to move the relayer without recreating light clients.`,
+		Args:    withUsage(cobra.MaximumNArgs(1)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients export clients.json`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			records, err := store.List()
+			if err != nil {
+				return err
+			}
+			bz, err := json.MarshalIndent(records, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), string(bz))
+				return nil
+			}
+			return os.WriteFile(args[0], bz, 0600)
+		},
+	}
+
+	return cmd
+}
+
+func clientsImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import file",
+		Short: "import light clients from a JSON file produced by 'clients export'",
+		Long: `Import light clients from a JSON file produced by 'clients export'.
+Existing records are not overwritten unless --overwrite is specified.
+Records exported before namespacing by Babylon chain ID are imported under
+their recorded Babylon chain ID, or under --babylon-chain-id if unknown.`,
+		Args:    withUsage(cobra.ExactArgs(1)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients import clients.json`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overwrite, err := cmd.Flags().GetBool("overwrite")
+			if err != nil {
+				return err
+			}
+			defaultBabylonChainID, err := cmd.Flags().GetString("babylon-chain-id")
+			if err != nil {
+				return err
+			}
+
+			bz, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read file %s: %w", args[0], err)
+			}
+			var exported map[bbnrelayer.ClientKey]*bbnrelayer.ClientRecord
+			if err := json.Unmarshal(bz, &exported); err != nil {
+				return fmt.Errorf("failed to unmarshal file %s: %w", args[0], err)
+			}
+
+			// scope records without Babylon chain ID in their keys
+			records := make(map[bbnrelayer.ClientKey]*bbnrelayer.ClientRecord, len(exported))
+			for key, record := range exported {
+				if record == nil || len(record.ClientID) == 0 {
+					return fmt.Errorf("record of chain %s has no client ID", key)
+				}
+				if len(key.BabylonChainID) == 0 {
+					key.BabylonChainID = record.BabylonChainID
+				}
+				if len(key.BabylonChainID) == 0 {
+					key.BabylonChainID = defaultBabylonChainID
+				}
+				if len(key.BabylonChainID) == 0 {
+					return fmt.Errorf("Babylon chain ID of chain %s is unknown, use --babylon-chain-id to specify it", key)
+				}
+				record.BabylonChainID = key.BabylonChainID
+				records[key] = record
+			}
+
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			// check for conflicts before writing anything
+			for key := range records {
+				if _, err := store.Get(key.BabylonChainID, key.CZChainID); err == nil && !overwrite {
+					return fmt.Errorf("client of chain %s already exists, use --overwrite to overwrite it", key)
+				} else if err != nil && !errors.Is(err, bbnrelayer.ErrClientNotFound) {
+					return err
+				}
+			}
+			for key, record := range records {
+				if err := store.Set(key.BabylonChainID, key.CZChainID, record); err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "imported %d clients\n", len(records))
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("overwrite", false, "overwrite existing records")
+	cmd.Flags().String("babylon-chain-id", "", "Babylon chain ID of records that do not specify one")
+
+	return cmd
+}
+
+func clientsMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate [cz_chain_id...]",
+		Short: "move light clients stored before namespacing under the given Babylon chain ID",
+		Long: `Move the light clients stored before namespacing by Babylon chain ID under the
+Babylon chain ID given by --babylon-chain-id, or only those of the given CZs.
+Such records do not know the Babylon network their clients belong to. The
+relayer moves them itself only if their clients exist on the Babylon chain it
+relays to and track the CZ.`,
+		Args:    withUsage(cobra.ArbitraryArgs),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s clients migrate --babylon-chain-id bbn-test-3`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			babylonChainID, err := cmd.Flags().GetString("babylon-chain-id")
+			if err != nil {
+				return err
+			}
+			if len(babylonChainID) == 0 {
+				return fmt.Errorf("--babylon-chain-id is required")
+			}
+
+			store, err := openClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			records, err := store.List()
+			if err != nil {
+				return err
+			}
+			czChainIDs := args
+			if len(czChainIDs) == 0 {
+				for key := range records {
+					if len(key.BabylonChainID) == 0 {
+						czChainIDs = append(czChainIDs, key.CZChainID)
+					}
+				}
+				sort.Strings(czChainIDs)
+			}
+
+			// check for conflicts before moving anything
+			for _, czChainID := range czChainIDs {
+				if _, ok := records[bbnrelayer.ClientKey{CZChainID: czChainID}]; !ok {
+					return fmt.Errorf("no client of chain %s is stored without a Babylon chain ID", czChainID)
+				}
+				if _, ok := records[bbnrelayer.ClientKey{BabylonChainID: babylonChainID, CZChainID: czChainID}]; ok {
+					return fmt.Errorf("client of chain %s already exists on chain %s, delete either record first", czChainID, babylonChainID)
+				}
+			}
+			for _, czChainID := range czChainIDs {
+				record := records[bbnrelayer.ClientKey{CZChainID: czChainID}]
+				record.BabylonChainID = babylonChainID
+				if err := store.Set(babylonChainID, czChainID, record); err != nil {
+					return err
+				}
+				if err := store.Delete("", czChainID); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "moved client %s of chain %s under chain %s\n", record.ClientID, czChainID, babylonChainID)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().String("babylon-chain-id", "", "chain ID of the Babylon chain that the clients belong to")
+
+	return cmd
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/babylonchain/babylon-relayer/bbnrelayer"
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/spf13/cobra"
+)
+
+// keysCheckGrantsCmd is the command for checking the grants to the keys that
+// sign client updates. It is added to the keys command of the official IBC relayer.
+func keysCheckGrantsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check-grants [babylon_chain_name]",
+		Short: "check the fee and authz grants to the keys that sign client updates",
+		Long: `Check that the fee and authz grants in the babylon section of the config file
+exist for every key in the key pool of each Babylon chain, or of the given
+Babylon chain, and have not expired.`,
+		Args:    withUsage(cobra.MaximumNArgs(1)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s keys check-grants babylon`, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			homePath, err := cmd.Flags().GetString("home")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadConfig(homePath, cmd)
+			if err != nil {
+				return err
+			}
+			if cfg.Babylon == nil {
+				return fmt.Errorf("no babylon section in config file, so no grants to check")
+			}
+
+			babylonChainCfgs := cfg.Babylon.Chains
+			if len(args) == 1 {
+				babylonChainCfg := cfg.Babylon.Chain(args[0])
+				if babylonChainCfg == nil {
+					return fmt.Errorf("Babylon chain %s not found in the babylon section of config file", args[0])
+				}
+				babylonChainCfgs = []*config.BabylonChainConfig{babylonChainCfg}
+			}
+
+			output := map[string][]bbnrelayer.GrantStatus{}
+			var errs []string
+			for _, babylonChainCfg := range babylonChainCfgs {
+				babylonChain := cfg.Chains[babylonChainCfg.ChainName]
+				keys := babylonChainCfg.KeyNames(babylonChain.ChainProvider.Key())
+				statuses, err := bbnrelayer.CheckGrants(cmd.Context(), babylonChain, keys, babylonChainCfg.Grants)
+				if err != nil {
+					errs = append(errs, err.Error())
+				}
+				output[babylonChainCfg.ChainName] = statuses
+			}
+			if err := printJSON(cmd, output); err != nil {
+				return err
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("%s", strings.Join(errs, "; "))
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/babylonchain/babylon-relayer/bbnrelayer"
+	"github.com/babylonchain/babylon-relayer/config"
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// recoverClientCmd is the command for creating a substitute client for an
+// expired or frozen CZ light client in Babylon
+func recoverClientCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recover-client babylon_chain_name cz_chain_name",
+		Short: "create a substitute for the expired or frozen IBC client on babylon_chain_name that tracks cz_chain_name",
+		Long: `Create a substitute for the expired or frozen IBC client on babylon_chain_name
+that tracks cz_chain_name, and record the substitution in the DB. The relayer
+keeps the substitute client updated until a MsgRecoverClient pointing the
+subject client at the substitute client is executed on babylon_chain_name via
+governance, and then resumes updating the subject client, so that the
+timestamps of cz_chain_name remain in the same client.
+With --proposal-file, the governance proposal with the MsgRecoverClient is
+written to the given file, to be submitted with 'tx gov submit-proposal'.
+The relayer must not be running, as it holds the DB.`,
+		Args: withUsage(cobra.ExactArgs(2)),
+		Example: strings.TrimSpace(fmt.Sprintf(`
+$ %s recover-client babylon osmosis
+$ %s recover-client babylon osmosis --proposal-file proposal.json --deposit 10000000ubbn`, AppName, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// load config
+			homePath, err := cmd.Flags().GetString("home")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadConfig(homePath, cmd)
+			if err != nil {
+				return err
+			}
+
+			logger, babylonChain, czChain, err := getLoggerAndChains(cmd, cfg, args)
+			if err != nil {
+				return err
+			}
+
+			// use the config of the CZ in the babylon section if any,
+			// where the flags are used for unspecified settings
+			var policy config.RelayPolicy
+			if policy.Retries, err = cmd.Flags().GetUint("retry"); err != nil {
+				return err
+			}
+			if policy.RetryDelay, err = cmd.Flags().GetDuration("retry-delay"); err != nil {
+				return err
+			}
+			var clientCfg config.ClientConfig
+			if czCfg := cfg.Babylon.CZ(args[0], args[1]); czCfg != nil {
+				czCfg.SetDefaults(policy)
+				policy = czCfg.RelayPolicy
+				clientCfg = czCfg.Client
+			}
+			shutdownGracePeriod, forceQuitTimeout, err := getShutdownGracePeriod(cmd)
+			if err != nil {
+				return err
+			}
+			setForceQuitTimeout(cmd.Context(), forceQuitTimeout)
+
+			proposalFile, err := cmd.Flags().GetString("proposal-file")
+			if err != nil {
+				return err
+			}
+			authority, err := cmd.Flags().GetString("authority")
+			if err != nil {
+				return err
+			}
+			deposit, err := cmd.Flags().GetString("deposit")
+			if err != nil {
+				return err
+			}
+			title, err := cmd.Flags().GetString("title")
+			if err != nil {
+				return err
+			}
+			summary, err := cmd.Flags().GetString("summary")
+			if err != nil {
+				return err
+			}
+			expedited, err := cmd.Flags().GetBool("expedited")
+			if err != nil {
+				return err
+			}
+
+			prometheusMetrics := relaydebug.NewPrometheusMetrics()
+			relayer, err := newRelayer(homePath, cfg, logger, prometheusMetrics, shutdownGracePeriod)
+			if err != nil {
+				return err
+			}
+			defer closeRelayer(logger, relayer)
+
+			// sign with the key pool and grants of the Babylon chain in the babylon section if any
+			if babylonChainCfg := cfg.Babylon.Chain(args[0]); babylonChainCfg != nil {
+				if err := relayer.UseSigners(cmd.Context(), babylonChain, babylonChainCfg); err != nil {
+					return err
+				}
+			}
+
+			substitution, err := relayer.RecoverClient(cmd.Context(), babylonChain, czChain, policy, clientCfg)
+			if err != nil {
+				return err
+			}
+			if err := printJSON(cmd, substitution); err != nil {
+				return err
+			}
+
+			if len(proposalFile) == 0 {
+				return nil
+			}
+			proposal, err := bbnrelayer.RecoverClientProposal(babylonChain, substitution, authority, deposit, title, summary, expedited)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(proposalFile, proposal, 0600); err != nil {
+				return fmt.Errorf("failed to write proposal file %s: %w", proposalFile, err)
+			}
+			logger.Info(
+				"Wrote the proposal to recover the client, submit it with `tx gov submit-proposal`",
+				zap.String("proposal_file", proposalFile),
+				zap.String("subject_client_id", substitution.SubjectClientID),
+				zap.String("substitute_client_id", substitution.SubstituteClientID),
+			)
+			return nil
+		},
+	}
+
+	cmd.Flags().Uint("retry", relayer.RtyAttNum, "number of retry attempts for requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("retry-delay", defaultRetryDelay, "the delay between two retry attempts of requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("shutdown-grace-period", defaultShutdownGracePeriod, "the period in which in-flight transactions are allowed to finish upon shutdown")
+	cmd.Flags().String("proposal-file", "", "the file to write the governance proposal with the MsgRecoverClient to")
+	cmd.Flags().String("authority", "", "the signer of the MsgRecoverClient (default the gov module account)")
+	cmd.Flags().String("deposit", "", "the deposit of the proposal, e.g., 10000000ubbn")
+	cmd.Flags().String("title", "", "the title of the proposal (default generated)")
+	cmd.Flags().String("summary", "", "the summary of the proposal (default generated)")
+	cmd.Flags().Bool("expedited", false, "whether the proposal is expedited")
+
+	return cmd
+}
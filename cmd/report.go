@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/bbnrelayer"
+	"github.com/babylonchain/babylon-relayer/config"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd is the command group for reporting from the history stored by the relayer
+func reportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "report from the history stored by the relayer",
+	}
+
+	cmd.AddCommand(
+		reportCostsCmd(),
+	)
+
+	return cmd
+}
+
+func reportCostsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "costs",
+		Short: "summarise the gas and fees spent on the client updates of each CZ",
+		Long: `Summarise the gas and fees spent on the txs sent to each Babylon chain per CZ
+since the given time, from the history stored by the relayer. The costs of a tx
+with msgs of several CZs, e.g., a batch of client updates, are split among the
+CZs in proportion to their numbers of msgs.
+While the relayer is running, it holds the DB, so the history is read from its
+status API at --relayer-addr.`,
+		Args: withUsage(cobra.ExactArgs(0)),
+		Example: strings.TrimSpace(fmt.Sprintf(`
+$ %s report costs --since 24h
+$ %s report costs --since 2024-01-01T00:00:00Z
+$ %s report costs --relayer-addr localhost:7597`, AppName, AppName, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceFlag, err := cmd.Flags().GetString("since")
+			if err != nil {
+				return err
+			}
+			since, err := parseSince(sinceFlag)
+			if err != nil {
+				return err
+			}
+
+			store, err := openReadOnlyCostStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			records, err := store.List(since)
+			if err != nil {
+				return err
+			}
+
+			output := struct {
+				Since  time.Time                 `json:"since"`
+				Chains []*bbnrelayer.CostSummary `json:"chains"`
+			}{
+				Since:  since,
+				Chains: bbnrelayer.SummarizeCosts(records),
+			}
+			return printJSON(cmd, output)
+		},
+	}
+
+	cmd.Flags().String("since", "24h", "the start of the report, either a duration before now or an RFC3339 time")
+	addRelayerAddrFlag(cmd)
+
+	return cmd
+}
+
+// openReadOnlyCostStore opens the cost store under the home path specified in
+// the flags of cmd for reading. While a relayer holds the DB, the history is
+// read from its status API at --relayer-addr instead.
+func openReadOnlyCostStore(cmd *cobra.Command) (bbnrelayer.CostStore, error) {
+	homePath, err := cmd.Flags().GetString("home")
+	if err != nil {
+		return nil, err
+	}
+	relayerAddr, err := cmd.Flags().GetString("relayer-addr")
+	if err != nil {
+		return nil, err
+	}
+	store, err := bbnrelayer.NewReadOnlyLevelDBCostStore(config.GetCostDBPath(homePath))
+	if err == nil {
+		return store, nil
+	}
+	if len(relayerAddr) == 0 {
+		return nil, fmt.Errorf("%w. If the relayer is running, pass its --debug-addr as --relayer-addr", err)
+	}
+	return bbnrelayer.NewAPICostStore(relayerAddr), nil
+}
+
+// parseSince parses the given duration before now or RFC3339 time
+func parseSince(since string) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("negative duration %v", d)
+		}
+		return time.Now().Add(-d).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected a duration or an RFC3339 time: %w", since, err)
+	}
+	return t.UTC(), nil
+}
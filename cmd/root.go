@@ -18,7 +18,6 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -33,6 +32,39 @@ import (
 
 const AppName = "babylon-relayer"
 
+// forceQuitMargin is the extra time given to a command to return after its
+// shutdown grace period before the program is forced to quit
+const forceQuitMargin = 10 * time.Second
+
+// shutdownDrainMargin is the extra time given to the relaying go routines to
+// record the results of the txs that are cancelled at the end of the shutdown
+// grace period and return, after which a warning is logged. It is shorter than
+// forceQuitMargin.
+const shutdownDrainMargin = 5 * time.Second
+
+// defaultForceQuitTimeout is the time after interruption after which the
+// program is forced to quit, unless the running command sets another one
+const defaultForceQuitTimeout = time.Minute
+
+// forceQuitTimeoutKey is the context key of the channel through which the
+// running command passes its force-quit timeout to Execute
+type forceQuitTimeoutKey struct{}
+
+// setForceQuitTimeout passes the given force-quit timeout of the running
+// command to Execute through the context of the command
+func setForceQuitTimeout(ctx context.Context, timeout time.Duration) {
+	ch, ok := ctx.Value(forceQuitTimeoutKey{}).(chan time.Duration)
+	if !ok {
+		return
+	}
+	// only the latest timeout is kept
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- timeout
+}
+
 // NewRootCmd returns the root command for relayer.
 // If log is nil, a new zap.Logger is set on the app state
 // based on the command line flags regarding logging.
@@ -58,9 +90,20 @@ func NewRootCmd(log *zap.Logger) *cobra.Command {
 		updateClientCmd(),
 		keepUpdatingClientCmd(),
 		keepUpdatingClientsCmd(),
+		recoverClientCmd(),
+		clientsCmd(),
+		reportCmd(),
+		statusCmd(),
 		lineBreakCommand(),
 	)
 
+	// add Babylon-specific subcommands to the commands of the official IBC relayer
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == "keys" {
+			c.AddCommand(keysCheckGrantsCmd())
+		}
+	}
+
 	return rootCmd
 }
 
@@ -75,6 +118,8 @@ func Execute() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	forceQuitTimeoutCh := make(chan time.Duration, 1)
+	ctx = context.WithValue(ctx, forceQuitTimeoutKey{}, forceQuitTimeoutCh)
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt) // Using signal.Notify, instead of signal.NotifyContext, in order to see details of signal.
@@ -94,12 +139,18 @@ func Execute() {
 		// Dump all goroutines on panic, not just the current one.
 		debug.SetTraceback("all")
 
+		forceQuitTimeout := defaultForceQuitTimeout
+		select {
+		case forceQuitTimeout = <-forceQuitTimeoutCh:
+		default:
+		}
+
 		// Block waiting for a second interrupt or a timeout.
 		// The main goroutine ought to finish before either case is reached.
 		// But if a case is reached, panic so that we get a non-zero exit and a dump of remaining goroutines.
 		select {
-		case <-time.After(time.Minute):
-			panic(errors.New("rly did not shut down within one minute of interrupt"))
+		case <-time.After(forceQuitTimeout):
+			panic(fmt.Errorf("rly did not shut down within %v of interrupt", forceQuitTimeout))
 		case sig := <-sigCh:
 			panic(fmt.Errorf("received signal %v; forcing quit", sig))
 		}
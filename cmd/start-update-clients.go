@@ -7,22 +7,26 @@ import (
 	"sync"
 	"time"
 
-	"github.com/avast/retry-go/v4"
 	"github.com/babylonchain/babylon-relayer/bbnrelayer"
 	"github.com/babylonchain/babylon-relayer/config"
 	relaydebug "github.com/babylonchain/babylon-relayer/debug"
-	"github.com/cosmos/relayer/v2/relayer"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 func keepUpdatingClientsCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "keep-update-clients",
-		Short:   "keep updating IBC client of a list of chains specified in config on Babylon",
-		Long:    `Keep updating IBC client of a list of chains specified in config on Babylon.`,
-		Args:    withUsage(cobra.ExactArgs(0)),
-		Example: strings.TrimSpace(fmt.Sprintf(`$ %s keep-update-clients`, AppName)),
+		Use:   "keep-update-clients",
+		Short: "keep updating IBC client of a list of chains specified in config on Babylon",
+		Long: `Keep updating IBC client of a list of chains specified in config on Babylon.
+The Babylon chains and the CZs enabled on each of them are specified in the
+babylon section of the config file. Without the babylon section, --cz
+specifies the CZs and the Babylon chain to which each of them is relayed, which
+can be omitted if --babylon-chain-name specifies a single Babylon chain.
+Relaying all chains other than --babylon-chain-name without --cz is deprecated.`,
+		Args: withUsage(cobra.ExactArgs(0)),
+		Example: strings.TrimSpace(fmt.Sprintf(`$ %s keep-update-clients --cz osmosis --cz juno
+$ %s keep-update-clients --babylon-chain-name babylon-testnet,babylon-devnet --cz osmosis=babylon-testnet --cz juno=babylon-devnet`, AppName, AppName)),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// load config
 			homePath, err := cmd.Flags().GetString("home")
@@ -48,29 +52,48 @@ func keepUpdatingClientsCmd() *cobra.Command {
 				return err
 			}
 
-			// get name of the Babylon chain specified in config
-			// by default, it's "babylon"
-			babylonChainName, err := cmd.Flags().GetString("babylon-chain-name")
+			// get the CZs to be relayed to each Babylon chain specified in config
+			// or flags
+			babylonCfg, err := getBabylonConfig(cmd, cfg, logger)
 			if err != nil {
 				return err
 			}
-			// get relay interval
-			interval, err := cmd.Flags().GetDuration("interval")
+			// get the default relay policy
+			policy, err := getRelayPolicy(cmd)
 			if err != nil {
 				return err
 			}
-			// override retry in relayer config
-			numRetries, err := cmd.Flags().GetUint("retry")
+			shutdownGracePeriod, forceQuitTimeout, err := getShutdownGracePeriod(cmd)
+			if err != nil {
+				return err
+			}
+			setForceQuitTimeout(cmd.Context(), forceQuitTimeout)
+			// Babylon chains and CZs without their own batch config and relay
+			// policy in config use the flags
+			batchCfg, err := getBatchConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if err := babylonCfg.SetDefaults(batchCfg, policy); err != nil {
+				return err
+			}
+			// get supervisor config for restarting failed chains
+			supervisorCfg, err := getSupervisorConfig(cmd)
 			if err != nil {
 				return err
 			}
-			relayer.RtyAttNum = numRetries
-			relayer.RtyAtt = retry.Attempts(relayer.RtyAttNum)
-			relayer.RtyDel = retry.Delay(time.Second)
 
 			// initialise prometheus registry
 			metrics := relaydebug.NewPrometheusMetrics()
-			// start debug server with prometheus metrics
+
+			// start the relayer for all paths in cfg.Paths
+			relayer, err := newRelayer(homePath, cfg, logger, metrics, shutdownGracePeriod)
+			if err != nil {
+				return err
+			}
+			defer closeRelayer(logger, relayer)
+
+			// start debug server with prometheus metrics and the status API of the relayer
 			debugAddr, err := cmd.Flags().GetString("debug-addr")
 			if err != nil {
 				return err
@@ -82,28 +105,41 @@ func keepUpdatingClientsCmd() *cobra.Command {
 			}
 			debugServerLogger := logger.With(zap.String("sys", "debughttp"))
 			debugServerLogger.Info("Debug server listening", zap.String("addr", debugAddr))
-			relaydebug.StartDebugServer(cmd.Context(), debugServerLogger, ln, metrics)
+			relaydebug.StartDebugServer(cmd.Context(), debugServerLogger, ln, metrics, relayer)
 
 			// we want the program to exit only after all go routines have finished
 			var wg sync.WaitGroup
-
-			// start the relayer for all paths in cfg.Paths
-			relayer := bbnrelayer.New(homePath, cfg, logger, metrics)
-			relayer.KeepUpdatingClients(cmd.Context(), &wg, babylonChainName, interval, numRetries)
+			relayer.KeepUpdatingClients(cmd.Context(), &wg, babylonCfg, supervisorCfg)
 
 			// Note that this function is executed inside `root.go`'s `Execute()` function,
 			// which keeps the program to be alive until being interrupted.
-			// Here we just need to keep the main thread to be alive all the time.
-			wg.Wait()
-
+			// Here we just need to keep the main thread to be alive until all go routines
+			// have finished, which happens within the grace period upon interruption,
+			// so that the stores are only closed once no go routine writes to them.
+			waitForShutdown(cmd.Context(), logger, &wg, shutdownGracePeriod)
 			return nil
 		},
 	}
 
-	cmd.Flags().String("babylon-chain-name", "babylon", "name of the Babylon chain in config file")
-	cmd.Flags().Duration("interval", time.Minute*10, "the interval between two update-client attempts")
-	cmd.Flags().Uint("retry", 5, "number of retry attempts for requests")
-	cmd.Flags().String("debug-addr", "", "address for the debug server with Prometheus metrics")
+	cmd.Flags().StringSlice("babylon-chain-name", []string{"babylon"}, "names of the Babylon chains in config file")
+	cmd.Flags().StringArray("cz", nil, "mapping from a CZ to the Babylon chain it is relayed to, in the form of cz_chain_name=babylon_chain_name, or cz_chain_name with a single Babylon chain")
+	cmd.Flags().Duration("interval", time.Minute*10, "the maximum interval between two update-client attempts, unless specified for the CZ in config")
+	cmd.Flags().Uint("retry", 5, "number of retry attempts for requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("retry-delay", defaultRetryDelay, "the delay between two retry attempts of requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("update-timeout", 0, "the timeout of an update-client attempt (0 for no timeout), unless specified for the CZ in config")
+	cmd.Flags().Float64("trust-fraction", defaultTrustFraction, "the fraction of the remaining trust window of a client within which the next update is scheduled, unless specified for the CZ in config")
+	cmd.Flags().String("mode", string(config.RelayModeInterval), "when to update a client, i.e., \"interval\" for every interval, or \"blocks\" for every --block-delta blocks of the CZ or every interval, whichever comes first, unless specified for the CZ in config")
+	cmd.Flags().Uint64("block-delta", 0, "the number of CZ blocks after which a client is updated in the blocks mode, unless specified for the CZ in config")
+	cmd.Flags().Duration("poll-interval", defaultPollInterval, "the interval of polling the latest height of a CZ in the blocks mode, unless specified for the CZ in config")
+	cmd.Flags().Duration("batch-window", 0, "the period in which client updates of CZs are collected and sent to a Babylon chain in a single tx (0 to disable batching), unless specified for the Babylon chain in config")
+	cmd.Flags().Int("batch-max-msgs", defaultBatchMaxMsgs, "the maximum number of client updates in a batch, unless specified for the Babylon chain in config")
+	cmd.Flags().String("debug-addr", "", "address for the debug server with Prometheus metrics and the status API")
+	cmd.Flags().Duration("shutdown-grace-period", defaultShutdownGracePeriod, "the period in which in-flight transactions are allowed to finish upon shutdown")
+	defaultSupervisorCfg := bbnrelayer.DefaultSupervisorConfig()
+	cmd.Flags().Duration("restart-backoff", defaultSupervisorCfg.InitialBackoff, "the initial delay before restarting the relaying of a failed chain")
+	cmd.Flags().Duration("max-restart-backoff", defaultSupervisorCfg.MaxBackoff, "the maximum delay before restarting the relaying of a failed chain")
+	cmd.Flags().Int("max-restarts", defaultSupervisorCfg.MaxRestarts, "the maximum number of restarts of a failed chain within --restart-window before giving up")
+	cmd.Flags().Duration("restart-window", defaultSupervisorCfg.RestartWindow, "the window in which restarts of a failed chain are counted")
 
 	return cmd
 }
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/babylonchain/babylon-relayer/bbnrelayer"
+	"github.com/babylonchain/babylon-relayer/config"
+	ibcexported "github.com/cosmos/ibc-go/v8/modules/core/exported"
+	"github.com/cosmos/relayer/v2/relayer"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// statusCmd is the command for summarising the health of the timestamping of
+// every CZ on each Babylon chain
+func statusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [babylon_chain_name]",
+		Short: "summarise the health of the timestamping of every CZ on each Babylon chain, or on babylon_chain_name",
+		Long: `Summarise the health of the timestamping of every CZ on each Babylon chain in
+the babylon section of the config file, or on babylon_chain_name, i.e., the
+stored client ID and its status on Babylon, the latest height of the CZ next
+to the latest height in the client, the time since the latest consensus state,
+the remaining trusting period and the balances of the accounts paying the fees
+of client updates, i.e., the fee granter, or else the keys signing them.
+Without a babylon section, the CZs with a client stored for babylon_chain_name
+are summarised. Expired and Frozen clients require a recovery action, in which
+case the command exits with an error.
+While the relayer is running, it holds the DB, so the stored clients are read
+from its status API at --relayer-addr.`,
+		Args: withUsage(cobra.MaximumNArgs(1)),
+		Example: strings.TrimSpace(fmt.Sprintf(`
+$ %s status
+$ %s status babylon --output json
+$ %s status --relayer-addr localhost:7597`, AppName, AppName, AppName)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if output != outputTable && output != outputJSON && output != outputYAML {
+				return fmt.Errorf("unknown output format %q, expected %q, %q or %q", output, outputTable, outputJSON, outputYAML)
+			}
+
+			homePath, err := cmd.Flags().GetString("home")
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadConfig(homePath, cmd)
+			if err != nil {
+				return err
+			}
+
+			store, err := openReadOnlyClientStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			targets, err := statusTargets(cfg, store, args)
+			if err != nil {
+				return err
+			}
+
+			// the CZs are queried concurrently, as each takes a few round trips
+			statuses := make([]*bbnrelayer.CZStatus, len(targets))
+			var wg sync.WaitGroup
+			for i, target := range targets {
+				i, target := i, target
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					statuses[i] = bbnrelayer.QueryCZStatus(cmd.Context(), store, target.babylonChain, target.czChain, target.payers)
+				}()
+			}
+			wg.Wait()
+			sort.SliceStable(statuses, func(i, j int) bool {
+				if statuses[i].BabylonChainID != statuses[j].BabylonChainID {
+					return statuses[i].BabylonChainID < statuses[j].BabylonChainID
+				}
+				return statuses[i].ChainID < statuses[j].ChainID
+			})
+
+			switch output {
+			case outputJSON:
+				err = printJSON(cmd, statuses)
+			case outputYAML:
+				err = printYAML(cmd, statuses)
+			default:
+				err = printStatusTable(cmd, statuses)
+			}
+			if err != nil {
+				return err
+			}
+
+			inactive := 0
+			for _, status := range statuses {
+				if status.ClientStatus == ibcexported.Expired.String() || status.ClientStatus == ibcexported.Frozen.String() {
+					inactive++
+				}
+			}
+			if inactive > 0 {
+				return fmt.Errorf("%d of %d clients are expired or frozen and require a recovery action", inactive, len(statuses))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", outputTable, "the output format, either table, json or yaml")
+	addRelayerAddrFlag(cmd)
+
+	return cmd
+}
+
+// statusTarget is a CZ whose status on a Babylon chain is summarised
+type statusTarget struct {
+	babylonChain *relayer.Chain
+	czChain      *relayer.Chain
+	payers       []bbnrelayer.FeePayer
+}
+
+// statusTargets returns the CZs of each Babylon chain in the babylon section
+// of the given config, or of the Babylon chain in the given args. Without a
+// babylon section, the CZs with a client stored for the Babylon chain in the
+// given args are returned.
+func statusTargets(cfg *config.Config, store bbnrelayer.ClientStore, args []string) ([]statusTarget, error) {
+	var targets []statusTarget
+	if cfg.Babylon != nil {
+		for _, babylonChainCfg := range cfg.Babylon.Chains {
+			if len(args) == 1 && babylonChainCfg.ChainName != args[0] {
+				continue
+			}
+			babylonChain := cfg.Chains[babylonChainCfg.ChainName]
+			for _, czCfg := range babylonChainCfg.CZs {
+				czChain, ok := cfg.Chains[czCfg.ChainName]
+				if !ok {
+					continue
+				}
+				payers := bbnrelayer.FeePayers(babylonChain, babylonChainCfg, cfg.Chains, czChain.ChainID())
+				targets = append(targets, statusTarget{babylonChain: babylonChain, czChain: czChain, payers: payers})
+			}
+		}
+		if len(args) == 0 || len(targets) > 0 {
+			return targets, nil
+		}
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no babylon section in config file, so babylon_chain_name has to be given")
+	}
+	babylonChain, ok := cfg.Chains[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("babylonChain %s not found in config. consider running `%s chains add %s`", args[0], AppName, args[0])
+	}
+	records, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for key := range records {
+		if key.BabylonChainID != babylonChain.ChainID() {
+			continue
+		}
+		// the CZ has to be in the config to be queried
+		czChain, err := cfg.Chains.Get(key.CZChainID)
+		if err != nil {
+			continue
+		}
+		payers := []bbnrelayer.FeePayer{{Name: babylonChain.ChainProvider.Key()}}
+		targets = append(targets, statusTarget{babylonChain: babylonChain, czChain: czChain, payers: payers})
+	}
+	return targets, nil
+}
+
+// printYAML prints the given object as YAML to the output of cmd
+func printYAML(cmd *cobra.Command, obj interface{}) error {
+	bz, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(cmd.OutOrStdout(), string(bz))
+	return nil
+}
+
+// printStatusTable prints the given statuses as a table to the output of cmd
+func printStatusTable(cmd *cobra.Command, statuses []*bbnrelayer.CZStatus) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BABYLON\tCZ\tCLIENT\tSTATUS\tCZ HEIGHT\tCLIENT HEIGHT\tSINCE UPDATE\tTRUST LEFT\tPAYER BALANCES\tERRORS")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.BabylonChainID,
+			s.ChainID,
+			orDash(s.ClientID),
+			orDash(s.ClientStatus),
+			orDash(formatHeight(uint64(s.CZLatestHeight))),
+			orDash(formatHeight(s.ClientLatestHeight)),
+			orDash(formatDuration(s.SinceLastConsensus, s.LastConsensusTime)),
+			orDash(formatDuration(s.TrustRemaining, s.LastConsensusTime)),
+			orDash(formatPayerBalances(s.Payers)),
+			orDash(strings.Join(s.Errors, "; ")),
+		)
+	}
+	return w.Flush()
+}
+
+// formatPayerBalances returns the known balances of the given payers
+func formatPayerBalances(payers []*bbnrelayer.PayerBalance) string {
+	balances := make([]string, 0, len(payers))
+	for _, p := range payers {
+		if len(p.Balance) > 0 {
+			balances = append(balances, p.Name+": "+p.Balance)
+		}
+	}
+	return strings.Join(balances, ", ")
+}
+
+// orDash returns the given string, or a dash if it is empty
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
+
+// formatHeight returns the given height, or an empty string if it is unknown
+func formatHeight(h uint64) string {
+	if h == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", h)
+}
+
+// formatDuration returns the given duration derived from the given time, or
+// an empty string if the time is unknown
+func formatDuration(d time.Duration, t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return d.String()
+}
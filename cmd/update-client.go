@@ -6,8 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/avast/retry-go/v4"
-	"github.com/babylonchain/babylon-relayer/bbnrelayer"
 	"github.com/babylonchain/babylon-relayer/config"
 	relaydebug "github.com/babylonchain/babylon-relayer/debug"
 	"github.com/cosmos/relayer/v2/relayer"
@@ -47,19 +45,44 @@ corresponding update-client message to babylon_chain_name.`,
 				return fmt.Errorf("key %s not found on babylonChain chain %s", babylonChain.ChainProvider.Key(), babylonChain.ChainID())
 			}
 
-			numRetries, err := cmd.Flags().GetUint("retry")
+			// use the relay policy of the CZ in the babylon section if any,
+			// where the flags are used for unspecified settings
+			policy, err := getRelayPolicy(cmd)
 			if err != nil {
 				return err
 			}
+			if czCfg := cfg.Babylon.CZ(args[0], args[1]); czCfg != nil {
+				czCfg.SetDefaults(policy)
+				policy = czCfg.RelayPolicy
+			}
+			shutdownGracePeriod, forceQuitTimeout, err := getShutdownGracePeriod(cmd)
+			if err != nil {
+				return err
+			}
+			setForceQuitTimeout(cmd.Context(), forceQuitTimeout)
 
 			prometheusMetrics := relaydebug.NewPrometheusMetrics()
-			relayer := bbnrelayer.New(homePath, cfg, logger, prometheusMetrics)
+			relayer, err := newRelayer(homePath, cfg, logger, prometheusMetrics, shutdownGracePeriod)
+			if err != nil {
+				return err
+			}
+			defer closeRelayer(logger, relayer)
+
+			// sign with the key pool and grants of the Babylon chain in the babylon section if any
+			if babylonChainCfg := cfg.Babylon.Chain(args[0]); babylonChainCfg != nil {
+				if err := relayer.UseSigners(cmd.Context(), babylonChain, babylonChainCfg); err != nil {
+					return err
+				}
+			}
 
-			return relayer.UpdateClient(cmd.Context(), babylonChain, czChain, numRetries)
+			return relayer.UpdateClient(cmd.Context(), babylonChain, czChain, policy)
 		},
 	}
 
-	cmd.Flags().Uint("retry", relayer.RtyAttNum, "number of retry attempts for requests")
+	cmd.Flags().Uint("retry", relayer.RtyAttNum, "number of retry attempts for requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("retry-delay", defaultRetryDelay, "the delay between two retry attempts of requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("update-timeout", 0, "the timeout of the update-client attempt (0 for no timeout), unless specified for the CZ in config")
+	cmd.Flags().Duration("shutdown-grace-period", defaultShutdownGracePeriod, "the period in which in-flight transactions are allowed to finish upon shutdown")
 
 	return cmd
 }
@@ -94,24 +117,36 @@ corresponding update-client message to babylon_chain_name.`,
 				return fmt.Errorf("key %s not found on babylonChain chain %s", babylonChain.ChainProvider.Key(), babylonChain.ChainID())
 			}
 
-			// retrieve necessary flags
-			interval, err := cmd.Flags().GetDuration("interval")
+			// use the config of the CZ in the babylon section if any,
+			// where the flags are used for unspecified settings
+			policy, err := getRelayPolicy(cmd)
 			if err != nil {
 				return err
 			}
-			// override retry in relayer config
-			numRetries, err := cmd.Flags().GetUint("retry")
+			shutdownGracePeriod, forceQuitTimeout, err := getShutdownGracePeriod(cmd)
 			if err != nil {
 				return err
 			}
-			relayer.RtyAttNum = numRetries
-			relayer.RtyAtt = retry.Attempts(relayer.RtyAttNum)
-			relayer.RtyDel = retry.Delay(time.Second)
+			setForceQuitTimeout(cmd.Context(), forceQuitTimeout)
+			czCfg := &config.CZConfig{ChainName: args[1]}
+			if cfgCZ := cfg.Babylon.CZ(args[0], args[1]); cfgCZ != nil {
+				czCfg = cfgCZ
+			}
+			czCfg.SetDefaults(policy)
+			if err := czCfg.RelayPolicy.Validate(); err != nil {
+				return fmt.Errorf("invalid relay policy of CZ %s: %w", czCfg.ChainName, err)
+			}
 
 			// initialise prometheus registry
 			metrics := relaydebug.NewPrometheusMetrics()
 
-			// start debug server with prometheus metrics
+			relayer, err := newRelayer(homePath, cfg, logger, metrics, shutdownGracePeriod)
+			if err != nil {
+				return err
+			}
+			defer closeRelayer(logger, relayer)
+
+			// start debug server with prometheus metrics and the status API of the relayer
 			debugAddr, err := cmd.Flags().GetString("debug-addr")
 			if err != nil {
 				return err
@@ -123,17 +158,29 @@ corresponding update-client message to babylon_chain_name.`,
 			}
 			debugServerLogger := logger.With(zap.String("sys", "debughttp"))
 			debugServerLogger.Info("Debug server listening", zap.String("addr", debugAddr))
-			relaydebug.StartDebugServer(cmd.Context(), debugServerLogger, ln, metrics)
+			relaydebug.StartDebugServer(cmd.Context(), debugServerLogger, ln, metrics, relayer)
 
-			relayer := bbnrelayer.New(homePath, cfg, logger, metrics)
+			// sign with the key pool and grants of the Babylon chain in the babylon section if any
+			if babylonChainCfg := cfg.Babylon.Chain(args[0]); babylonChainCfg != nil {
+				if err := relayer.UseSigners(cmd.Context(), babylonChain, babylonChainCfg); err != nil {
+					return err
+				}
+			}
 
-			return relayer.KeepUpdatingClient(cmd.Context(), babylonChain, czChain, interval, numRetries)
+			return relayer.KeepUpdatingClient(cmd.Context(), babylonChain, czChain, czCfg)
 		},
 	}
 
-	cmd.Flags().Duration("interval", time.Minute*10, "the interval between two update-client attempts")
-	cmd.Flags().Uint("retry", 5, "number of retry attempts for requests")
-	cmd.Flags().String("debug-addr", "", "address for the debug server with Prometheus metrics")
+	cmd.Flags().Duration("interval", time.Minute*10, "the maximum interval between two update-client attempts, unless specified for the CZ in config")
+	cmd.Flags().Uint("retry", 5, "number of retry attempts for requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("retry-delay", defaultRetryDelay, "the delay between two retry attempts of requests, unless specified for the CZ in config")
+	cmd.Flags().Duration("update-timeout", 0, "the timeout of an update-client attempt (0 for no timeout), unless specified for the CZ in config")
+	cmd.Flags().Float64("trust-fraction", defaultTrustFraction, "the fraction of the remaining trust window of a client within which the next update is scheduled, unless specified for the CZ in config")
+	cmd.Flags().String("mode", string(config.RelayModeInterval), "when to update a client, i.e., \"interval\" for every interval, or \"blocks\" for every --block-delta blocks of the CZ or every interval, whichever comes first, unless specified for the CZ in config")
+	cmd.Flags().Uint64("block-delta", 0, "the number of CZ blocks after which a client is updated in the blocks mode, unless specified for the CZ in config")
+	cmd.Flags().Duration("poll-interval", defaultPollInterval, "the interval of polling the latest height of a CZ in the blocks mode, unless specified for the CZ in config")
+	cmd.Flags().String("debug-addr", "", "address for the debug server with Prometheus metrics and the status API")
+	cmd.Flags().Duration("shutdown-grace-period", defaultShutdownGracePeriod, "the period in which in-flight transactions are allowed to finish upon shutdown")
 
 	return cmd
 }
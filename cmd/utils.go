@@ -1,15 +1,37 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/babylonchain/babylon-relayer/bbnrelayer"
 	"github.com/babylonchain/babylon-relayer/config"
-	relayercmd "github.com/cosmos/relayer/v2/cmd"
+	relaydebug "github.com/babylonchain/babylon-relayer/debug"
 	"github.com/cosmos/relayer/v2/relayer"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+const (
+	// defaultShutdownGracePeriod is the default period in which in-flight
+	// transactions are allowed to finish upon shutdown
+	defaultShutdownGracePeriod = 30 * time.Second
+	// defaultRetryDelay is the default delay between two retry attempts of requests
+	defaultRetryDelay = time.Second
+	// defaultTrustFraction is the default fraction of the remaining trust window
+	// of a client within which the next update is scheduled
+	defaultTrustFraction = 0.5
+	// defaultPollInterval is the default interval of polling the latest height
+	// of a CZ in the blocks mode
+	defaultPollInterval = 5 * time.Second
+	// defaultBatchMaxMsgs is the default maximum number of client updates in a batch
+	defaultBatchMaxMsgs = 20
+)
+
 // withUsage wraps a PositionalArgs to display usage only when the PositionalArgs
 // variant is violated.
 // (adapted from https://github.com/cosmos/relayer/blob/v2.1.2/cmd/root.go#L229)
@@ -32,9 +54,15 @@ func lineBreakCommand() *cobra.Command {
 	return &cobra.Command{Run: func(*cobra.Command, []string) {}}
 }
 
+// addRelayerAddrFlag adds the flag of the address of the status API of a
+// running relayer, from which read-only commands read the stores it holds
+func addRelayerAddrFlag(cmd *cobra.Command) {
+	cmd.Flags().String("relayer-addr", "", "the --debug-addr of the running relayer, whose status API is read while the relayer holds the DB")
+}
+
 // getLoggerAndChains is a helper function that retrieves the logger, babylonChain and czChain
 // from the given cmd and args
-func getLoggerAndChains(cmd *cobra.Command, cfg *relayercmd.Config, args []string) (*zap.Logger, *relayer.Chain, *relayer.Chain, error) {
+func getLoggerAndChains(cmd *cobra.Command, cfg *config.Config, args []string) (*zap.Logger, *relayer.Chain, *relayer.Chain, error) {
 	// construct logger
 	logFormat, err := cmd.Flags().GetString("log-format")
 	if err != nil {
@@ -63,3 +91,260 @@ func getLoggerAndChains(cmd *cobra.Command, cfg *relayercmd.Config, args []strin
 
 	return logger, babylonChain, czChain, nil
 }
+
+// getSupervisorConfig retrieves the config of the supervisor of relaying loops
+// from the flags of the given cmd
+func getSupervisorConfig(cmd *cobra.Command) (bbnrelayer.SupervisorConfig, error) {
+	var (
+		cfg bbnrelayer.SupervisorConfig
+		err error
+	)
+	if cfg.InitialBackoff, err = cmd.Flags().GetDuration("restart-backoff"); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxBackoff, err = cmd.Flags().GetDuration("max-restart-backoff"); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxRestarts, err = cmd.Flags().GetInt("max-restarts"); err != nil {
+		return cfg, err
+	}
+	if cfg.RestartWindow, err = cmd.Flags().GetDuration("restart-window"); err != nil {
+		return cfg, err
+	}
+	if cfg.InitialBackoff <= 0 || cfg.MaxBackoff < cfg.InitialBackoff {
+		return cfg, fmt.Errorf("invalid restart backoff: initial %v, max %v", cfg.InitialBackoff, cfg.MaxBackoff)
+	}
+
+	return cfg, nil
+}
+
+// getRelayPolicy retrieves the default relay policy from the flags of the given
+// cmd, which applies to CZs that do not specify their own in config. The
+// settings for scheduling updates, e.g., the interval and the mode, are only
+// retrieved if the cmd has the flags.
+func getRelayPolicy(cmd *cobra.Command) (config.RelayPolicy, error) {
+	var (
+		policy config.RelayPolicy
+		err    error
+	)
+	if cmd.Flags().Lookup("interval") != nil {
+		if policy.Interval, err = cmd.Flags().GetDuration("interval"); err != nil {
+			return policy, err
+		}
+		if policy.Interval <= 0 {
+			return policy, fmt.Errorf("invalid interval %v", policy.Interval)
+		}
+	}
+	if policy.Retries, err = cmd.Flags().GetUint("retry"); err != nil {
+		return policy, err
+	}
+	if policy.RetryDelay, err = cmd.Flags().GetDuration("retry-delay"); err != nil {
+		return policy, err
+	}
+	if policy.Timeout, err = cmd.Flags().GetDuration("update-timeout"); err != nil {
+		return policy, err
+	}
+	if cmd.Flags().Lookup("trust-fraction") != nil {
+		if policy.TrustFraction, err = cmd.Flags().GetFloat64("trust-fraction"); err != nil {
+			return policy, err
+		}
+		if policy.TrustFraction <= 0 || policy.TrustFraction > 1 {
+			return policy, fmt.Errorf("invalid trust fraction %v, expected a value in (0, 1]", policy.TrustFraction)
+		}
+	}
+	if cmd.Flags().Lookup("mode") != nil {
+		mode, err := cmd.Flags().GetString("mode")
+		if err != nil {
+			return policy, err
+		}
+		policy.Mode = config.RelayMode(mode)
+		if policy.BlockDelta, err = cmd.Flags().GetUint64("block-delta"); err != nil {
+			return policy, err
+		}
+		if policy.PollInterval, err = cmd.Flags().GetDuration("poll-interval"); err != nil {
+			return policy, err
+		}
+	}
+	if policy.RetryDelay < 0 || policy.Timeout < 0 {
+		return policy, fmt.Errorf("invalid retry delay %v or timeout %v", policy.RetryDelay, policy.Timeout)
+	}
+
+	return policy, nil
+}
+
+// getBatchConfig retrieves the default config of sending client updates in
+// batches from the flags of the given cmd
+func getBatchConfig(cmd *cobra.Command) (config.BatchConfig, error) {
+	var (
+		cfg config.BatchConfig
+		err error
+	)
+	if cfg.Window, err = cmd.Flags().GetDuration("batch-window"); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxMsgs, err = cmd.Flags().GetInt("batch-max-msgs"); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxMsgs < 0 {
+		return cfg, fmt.Errorf("invalid max number of client updates %d in a batch", cfg.MaxMsgs)
+	}
+
+	return cfg, nil
+}
+
+// getShutdownGracePeriod retrieves the shutdown grace period from the flags of
+// the given cmd, and returns it together with the force-quit timeout of the
+// cmd, which leaves time for the cmd to return after the grace period
+func getShutdownGracePeriod(cmd *cobra.Command) (time.Duration, time.Duration, error) {
+	gracePeriod, err := cmd.Flags().GetDuration("shutdown-grace-period")
+	if err != nil {
+		return 0, 0, err
+	}
+	if gracePeriod < 0 {
+		return 0, 0, fmt.Errorf("invalid shutdown grace period %v", gracePeriod)
+	}
+	forceQuitTimeout := defaultForceQuitTimeout
+	if forceQuitTimeout < gracePeriod+forceQuitMargin {
+		forceQuitTimeout = gracePeriod + forceQuitMargin
+	}
+
+	return gracePeriod, forceQuitTimeout, nil
+}
+
+// waitForShutdown waits until all go routines in wg have finished, so that
+// the stores they write to can be closed afterwards. Once ctx is done,
+// in-flight txs are allowed to finish within the given grace period, after
+// which they are cancelled and the go routines record their results and
+// return. Go routines that have not returned by then are still waited for,
+// until the program is forced to quit.
+func waitForShutdown(ctx context.Context, logger *zap.Logger, wg *sync.WaitGroup, gracePeriod time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-done:
+		return
+	case <-time.After(gracePeriod + shutdownDrainMargin):
+		logger.Warn(
+			"Relayer did not shut down within the grace period, waiting for the remaining go routines",
+			zap.Duration("grace_period", gracePeriod),
+		)
+	}
+	<-done
+}
+
+// newRelayer opens the client store under the given home path and returns a
+// relayer that owns it. The caller needs to close the relayer after use.
+func newRelayer(
+	homePath string,
+	cfg *config.Config,
+	logger *zap.Logger,
+	metrics *relaydebug.PrometheusMetrics,
+	shutdownGracePeriod time.Duration,
+) (*bbnrelayer.Relayer, error) {
+	clientStore, err := bbnrelayer.NewLevelDBClientStore(config.GetDBPath(homePath))
+	if err != nil {
+		return nil, err
+	}
+	costStore, err := bbnrelayer.NewLevelDBCostStore(config.GetCostDBPath(homePath))
+	if err != nil {
+		clientStore.Close()
+		return nil, err
+	}
+	return bbnrelayer.New(homePath, cfg.Config, logger, metrics, clientStore, costStore, shutdownGracePeriod), nil
+}
+
+// closeRelayer closes the given relayer, which flushes its client store and cost store
+func closeRelayer(logger *zap.Logger, relayer *bbnrelayer.Relayer) {
+	if err := relayer.Close(); err != nil {
+		logger.Error("failed to close the relayer", zap.Error(err))
+	}
+}
+
+// getBabylonConfig retrieves the CZs to be relayed to each Babylon chain. If
+// the config file has a babylon section, it is used as is. Otherwise, the
+// Babylon chains and CZs are retrieved from the flags of the given cmd, where
+// each CZ is mapped to a Babylon chain, which can be omitted with a single
+// Babylon chain. Without any mapping, all other chains in config are relayed
+// to a single Babylon chain, which is deprecated.
+func getBabylonConfig(cmd *cobra.Command, cfg *config.Config, logger *zap.Logger) (*config.BabylonConfig, error) {
+	if cfg.Babylon != nil {
+		if cmd.Flags().Changed("babylon-chain-name") || cmd.Flags().Changed("cz") {
+			return nil, fmt.Errorf("--babylon-chain-name and --cz cannot be used when the config file has a babylon section")
+		}
+		return cfg.Babylon, nil
+	}
+
+	babylonChainNames, err := cmd.Flags().GetStringSlice("babylon-chain-name")
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := cmd.Flags().GetStringArray("cz")
+	if err != nil {
+		return nil, err
+	}
+	if len(babylonChainNames) == 0 {
+		return nil, fmt.Errorf("at least one Babylon chain is required")
+	}
+
+	babylonCfg := &config.BabylonConfig{}
+	babylonChainCfgs := map[string]*config.BabylonChainConfig{}
+	for _, babylonChainName := range babylonChainNames {
+		if _, ok := cfg.Chains[babylonChainName]; !ok {
+			return nil, fmt.Errorf("babylonChain %s not found in config. consider running `%s chains add %s`", babylonChainName, AppName, babylonChainName)
+		}
+		babylonChainCfg := &config.BabylonChainConfig{ChainName: babylonChainName}
+		babylonCfg.Chains = append(babylonCfg.Chains, babylonChainCfg)
+		babylonChainCfgs[babylonChainName] = babylonChainCfg
+	}
+
+	if len(mappings) == 0 {
+		if len(babylonChainNames) > 1 {
+			return nil, fmt.Errorf("--cz is required to map CZs to multiple Babylon chains")
+		}
+		var czChainNames []string
+		for chainName := range cfg.Chains {
+			if chainName != babylonChainNames[0] {
+				czChainNames = append(czChainNames, chainName)
+			}
+		}
+		sort.Strings(czChainNames)
+		logger.Warn(
+			"Relaying every other chain in config as a CZ without a babylon section or --cz is deprecated. Add a babylon section to the config file or specify the CZs with --cz",
+			zap.String("babylon_chain_name", babylonChainNames[0]),
+			zap.Strings("cz_chain_names", czChainNames),
+		)
+		for _, chainName := range czChainNames {
+			babylonCfg.Chains[0].CZs = append(babylonCfg.Chains[0].CZs, &config.CZConfig{ChainName: chainName})
+		}
+	}
+	for _, mapping := range mappings {
+		czChainName, babylonChainName, ok := strings.Cut(mapping, "=")
+		if !ok && len(babylonChainNames) == 1 {
+			// the only Babylon chain can be omitted
+			babylonChainName, ok = babylonChainNames[0], len(czChainName) > 0
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid CZ mapping %q, expected cz_chain_name=babylon_chain_name", mapping)
+		}
+		babylonChainCfg, ok := babylonChainCfgs[babylonChainName]
+		if !ok {
+			return nil, fmt.Errorf("CZ %s is mapped to %s, which is not specified in --babylon-chain-name", czChainName, babylonChainName)
+		}
+		babylonChainCfg.CZs = append(babylonChainCfg.CZs, &config.CZConfig{ChainName: czChainName})
+	}
+
+	if err := babylonCfg.Validate(cfg.Chains); err != nil {
+		return nil, err
+	}
+	return babylonCfg, nil
+}
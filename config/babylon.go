@@ -0,0 +1,569 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	relayer "github.com/cosmos/relayer/v2/relayer"
+	"github.com/cosmos/relayer/v2/relayer/chains/cosmos"
+	"go.uber.org/zap/zapcore"
+)
+
+// BabylonConfig is the Babylon-specific section of the config file, i.e.,
+//
+//	babylon:
+//	  chains:
+//	    - chain-name: babylon
+//	      batch:
+//	        window: 2s
+//	        max-msgs: 20
+//	      keys:
+//	        names: [relayer-1, relayer-2]
+//	        strategy: round-robin
+//	      grants:
+//	        fee-granter: bbn1...
+//	        authz-granter: bbn1...
+//	      balance:
+//	        interval: 1m
+//	        denom: ubbn
+//	        warn-threshold: 10000000
+//	        critical-threshold: 1000000
+//	        pause-non-critical: true
+//	      czs:
+//	        - chain-name: osmosis
+//	          key: relayer-2
+//	          critical: true
+//	          misbehaviour:
+//	            witnesses: [https://rpc.osmosis.example.com:443]
+//	            interval: 5m
+//	          upgrade:
+//	            previous-rpc-addr: https://rpc.osmo-test-4.example.com:443
+//	          interval: 10m
+//	          retries: 5
+//	          retry-delay: 1s
+//	          timeout: 1m
+//	          trust-fraction: 0.5
+//	          mode: blocks
+//	          block-delta: 100
+//	          poll-interval: 5s
+//
+// where chain names refer to the chains in the `chains` section. Only the CZs
+// listed here are relayed to the corresponding Babylon chain.
+type BabylonConfig struct {
+	Chains []*BabylonChainConfig `yaml:"chains"`
+}
+
+// BabylonChainConfig is the config of a Babylon chain and the CZs relayed to it
+type BabylonChainConfig struct {
+	// ChainName is the name of the Babylon chain in the `chains` section
+	ChainName string `yaml:"chain-name"`
+	// Batch is the config of sending the client updates of CZs in batches
+	Batch BatchConfig `yaml:"batch,omitempty"`
+	// Keys is the pool of keys that sign the client updates of CZs
+	Keys KeyPoolConfig `yaml:"keys,omitempty"`
+	// Grants is the grants to the keys, so that the keys need neither funds
+	// nor permissions themselves
+	Grants GrantsConfig `yaml:"grants,omitempty"`
+	// Balance is the config of watching the balances of the accounts that
+	// pay the fees of client updates
+	Balance BalanceConfig `yaml:"balance,omitempty"`
+	CZs     []*CZConfig   `yaml:"czs"`
+}
+
+// DefaultBalanceInterval is the default interval of querying the balances of
+// the accounts that pay the fees of client updates
+const DefaultBalanceInterval = time.Minute
+
+// BalanceConfig is the config of watching the balances of the accounts that
+// pay the fees of client updates on a Babylon chain, i.e., the fee granter if
+// any, or otherwise the keys in the pool
+type BalanceConfig struct {
+	// Interval is the interval of querying the balances, where a negative
+	// interval disables the watcher. Defaults to DefaultBalanceInterval.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Denom is the denom of the fees, which defaults to the denom of the gas
+	// prices of the chain
+	Denom string `yaml:"denom,omitempty"`
+	// WarnThreshold is the balance below which a warning is logged, where 0
+	// disables the warning
+	WarnThreshold uint64 `yaml:"warn-threshold,omitempty"`
+	// CriticalThreshold is the balance below which an error is logged, where
+	// 0 disables the error
+	CriticalThreshold uint64 `yaml:"critical-threshold,omitempty"`
+	// PauseNonCritical pauses the client updates of CZs that are not critical
+	// while the balance of any account is below the critical threshold
+	PauseNonCritical bool `yaml:"pause-non-critical,omitempty"`
+}
+
+// GrantsConfig is the grants of a Babylon chain to the keys that sign client
+// updates, where the granters are addresses that need not be in the keyring
+type GrantsConfig struct {
+	// FeeGranter pays the fees of the txs via x/feegrant
+	FeeGranter string `yaml:"fee-granter,omitempty"`
+	// AuthzGranter is the signer of the msgs, which are wrapped in a MsgExec
+	// of x/authz signed by the keys as grantees
+	AuthzGranter string `yaml:"authz-granter,omitempty"`
+}
+
+// KeyStrategy decides which key in the pool signs a client update
+type KeyStrategy string
+
+const (
+	// KeyStrategyRoundRobin uses the keys in turn
+	KeyStrategyRoundRobin KeyStrategy = "round-robin"
+	// KeyStrategyLeastRecentlyUsed uses the key that has been idle for the longest time
+	KeyStrategyLeastRecentlyUsed KeyStrategy = "least-recently-used"
+	// KeyStrategyPinned always uses the same key for a CZ, where CZs without
+	// a key in their config are assigned to the keys in turn
+	KeyStrategyPinned KeyStrategy = "pinned"
+)
+
+// KeyPoolConfig is the pool of keys in the keyring of a Babylon chain that
+// sign client updates. An empty pool only has the key of the chain in the
+// `chains` section.
+type KeyPoolConfig struct {
+	Names []string `yaml:"names,omitempty"`
+	// Strategy defaults to round-robin
+	Strategy KeyStrategy `yaml:"strategy,omitempty"`
+}
+
+// BatchConfig is the config of sending the client updates of CZs to a Babylon
+// chain in batches. Zero values are replaced by the defaults from the command
+// line flags.
+type BatchConfig struct {
+	// Window is the period in which client updates are collected into a batch,
+	// where a negative window disables batching
+	Window time.Duration `yaml:"window,omitempty"`
+	// MaxMsgs is the maximum number of client updates in a batch
+	MaxMsgs int `yaml:"max-msgs,omitempty"`
+}
+
+// CZConfig is the config of relaying a CZ to a Babylon chain
+type CZConfig struct {
+	// ChainName is the name of the CZ in the `chains` section
+	ChainName string `yaml:"chain-name"`
+	// Key is the key in the pool that signs the client updates of the CZ,
+	// regardless of the strategy of the pool
+	Key string `yaml:"key,omitempty"`
+	// Critical keeps the client of the CZ updated while the balance of the
+	// Babylon chain is below the critical threshold
+	Critical    bool `yaml:"critical,omitempty"`
+	RelayPolicy `yaml:",inline"`
+	// Client is the parameters of the light client of the CZ on Babylon
+	Client ClientConfig `yaml:"client,omitempty"`
+	// Misbehaviour is the config of checking the client of the CZ for misbehaviour
+	Misbehaviour MisbehaviourConfig `yaml:"misbehaviour,omitempty"`
+	// Upgrade is the config of following the CZ to a new revision
+	Upgrade UpgradeConfig `yaml:"upgrade,omitempty"`
+}
+
+// UpgradeConfig is the config of following a CZ to a new revision, e.g., from
+// osmo-test-4 to osmo-test-5, once its chain ID is changed in the `chains` section
+type UpgradeConfig struct {
+	// PreviousRPCAddr is the RPC address of a node of the previous revision of
+	// the CZ, which serves the upgrade plan and the proofs of the upgraded
+	// client for MsgUpgradeClient. Without it, or if the previous revision has
+	// no IBC upgrade plan, a new client is created for the new revision.
+	PreviousRPCAddr string `yaml:"previous-rpc-addr,omitempty"`
+}
+
+// MisbehaviourConfig is the config of checking the consensus states of the
+// client of a CZ on Babylon against the headers of independent CZ nodes
+type MisbehaviourConfig struct {
+	// Witnesses are the RPC addresses of CZ nodes independent of the one in
+	// the `chains` section, where no witness disables the check
+	Witnesses []string `yaml:"witnesses,omitempty"`
+	// Interval is the interval between two checks, which defaults to the
+	// interval of the relay policy
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// HaltOnly only halts the updates of the client upon a conflicting header,
+	// rather than also submitting the misbehaviour to Babylon
+	HaltOnly bool `yaml:"halt-only,omitempty"`
+}
+
+// RelayMode decides when the client of a CZ is updated
+type RelayMode string
+
+const (
+	// RelayModeInterval updates the client periodically
+	RelayModeInterval RelayMode = "interval"
+	// RelayModeBlocks updates the client once the CZ has advanced a number
+	// of blocks since the last update, or periodically, whichever comes first
+	RelayModeBlocks RelayMode = "blocks"
+)
+
+// RelayPolicy is the policy of relaying a CZ to a Babylon chain. Zero values
+// are replaced by the defaults from the command line flags.
+type RelayPolicy struct {
+	// Interval is the interval between two update-client attempts
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Retries is the number of retry attempts for requests
+	Retries uint `yaml:"retries,omitempty"`
+	// RetryDelay is the delay between two retry attempts
+	RetryDelay time.Duration `yaml:"retry-delay,omitempty"`
+	// Timeout is the timeout of an update-client attempt, where 0 means no timeout
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// TrustFraction is the fraction of the remaining trust window of the client
+	// within which the next update is scheduled, in (0, 1]
+	TrustFraction float64 `yaml:"trust-fraction,omitempty"`
+	// Mode decides when the client is updated
+	Mode RelayMode `yaml:"mode,omitempty"`
+	// BlockDelta is the number of CZ blocks after which the client is updated in the blocks mode
+	BlockDelta uint64 `yaml:"block-delta,omitempty"`
+	// PollInterval is the interval of polling the latest height of the CZ in the blocks mode
+	PollInterval time.Duration `yaml:"poll-interval,omitempty"`
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler so that the policy can be logged
+func (p RelayPolicy) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddDuration("interval", p.Interval)
+	enc.AddUint("retries", p.Retries)
+	enc.AddDuration("retry_delay", p.RetryDelay)
+	enc.AddDuration("timeout", p.Timeout)
+	enc.AddFloat64("trust_fraction", p.TrustFraction)
+	enc.AddString("mode", string(p.Mode))
+	if p.Mode == RelayModeBlocks {
+		enc.AddUint64("block_delta", p.BlockDelta)
+		enc.AddDuration("poll_interval", p.PollInterval)
+	}
+	return nil
+}
+
+// Validate checks the policy after the defaults have been set
+func (p *RelayPolicy) Validate() error {
+	if p.Interval <= 0 {
+		return fmt.Errorf("invalid interval %v", p.Interval)
+	}
+	if p.RetryDelay < 0 || p.Timeout < 0 {
+		return fmt.Errorf("invalid retry delay %v or timeout %v", p.RetryDelay, p.Timeout)
+	}
+	if p.TrustFraction <= 0 || p.TrustFraction > 1 {
+		return fmt.Errorf("trust fraction %v is not in (0, 1]", p.TrustFraction)
+	}
+	switch p.Mode {
+	case RelayModeInterval:
+	case RelayModeBlocks:
+		if p.BlockDelta == 0 || p.PollInterval <= 0 {
+			return fmt.Errorf("blocks mode requires a positive block delta and poll interval, got %d and %v", p.BlockDelta, p.PollInterval)
+		}
+	default:
+		return fmt.Errorf("unknown relay mode %q, expected %q or %q", p.Mode, RelayModeInterval, RelayModeBlocks)
+	}
+	return nil
+}
+
+// ClientConfig is the parameters for creating the light client of a CZ on Babylon.
+// Zero values are calculated by the relayer from the staking params of the CZ,
+// or replaced by the defaults of the relayer.
+type ClientConfig struct {
+	TrustingPeriod  time.Duration `yaml:"trusting-period,omitempty"`
+	UnbondingPeriod time.Duration `yaml:"unbonding-period,omitempty"`
+	// TrustingPeriodPercentage is the percentage of the unbonding period used
+	// as the trusting period if the latter is not specified, in (0, 100)
+	TrustingPeriodPercentage uint `yaml:"trusting-period-percentage,omitempty"`
+	// MaxClockDrift is the maximum clock drift allowed between Babylon and the CZ
+	MaxClockDrift time.Duration `yaml:"max-clock-drift,omitempty"`
+	// TrustLevel is the fraction of the voting power of the trusted validators
+	// that must sign a header skipping heights, e.g., 1/3, in [1/3, 1]
+	TrustLevel string `yaml:"trust-level,omitempty"`
+	// AllowUpdateAfterExpiry and AllowUpdateAfterMisbehaviour are the
+	// deprecated flags of the client, which are set unless disabled here
+	AllowUpdateAfterExpiry       *bool `yaml:"allow-update-after-expiry,omitempty"`
+	AllowUpdateAfterMisbehaviour *bool `yaml:"allow-update-after-misbehaviour,omitempty"`
+	// Override is whether a new client is always created, which is the
+	// default, rather than reusing an active client on Babylon whose state
+	// matches the client to be created
+	Override *bool `yaml:"override,omitempty"`
+}
+
+// ParseTrustLevel parses the given trust level in the form of numerator/denominator,
+// which must be in [1/3, 1] as required by the light client
+func ParseTrustLevel(trustLevel string) (uint64, uint64, error) {
+	numStr, denStr, ok := strings.Cut(trustLevel, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("trust level %q is not in the form of numerator/denominator", trustLevel)
+	}
+	num, err := strconv.ParseUint(strings.TrimSpace(numStr), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid numerator of trust level %q: %w", trustLevel, err)
+	}
+	den, err := strconv.ParseUint(strings.TrimSpace(denStr), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid denominator of trust level %q: %w", trustLevel, err)
+	}
+	if den == 0 || num > den || num*3 < den {
+		return 0, 0, fmt.Errorf("trust level %q is not in [1/3, 1]", trustLevel)
+	}
+	return num, den, nil
+}
+
+// Validate checks that the given client parameters are consistent
+func (c *ClientConfig) Validate() error {
+	if c.TrustingPeriod < 0 || c.UnbondingPeriod < 0 {
+		return fmt.Errorf("negative trusting period %v or unbonding period %v", c.TrustingPeriod, c.UnbondingPeriod)
+	}
+	if c.TrustingPeriod > 0 && c.UnbondingPeriod > 0 && c.TrustingPeriod >= c.UnbondingPeriod {
+		return fmt.Errorf("trusting period %v is not shorter than unbonding period %v", c.TrustingPeriod, c.UnbondingPeriod)
+	}
+	if c.TrustingPeriodPercentage >= 100 {
+		return fmt.Errorf("trusting period percentage %d is not in (0, 100)", c.TrustingPeriodPercentage)
+	}
+	if c.MaxClockDrift < 0 {
+		return fmt.Errorf("negative max clock drift %v", c.MaxClockDrift)
+	}
+	if len(c.TrustLevel) > 0 {
+		if _, _, err := ParseTrustLevel(c.TrustLevel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks that the Babylon-specific section is consistent with the
+// given chains in the `chains` section
+func (c *BabylonConfig) Validate(chains relayer.Chains) error {
+	if len(c.Chains) == 0 {
+		return fmt.Errorf("no Babylon chain is specified in the babylon section")
+	}
+
+	babylonChainNames := map[string]bool{}
+	for _, babylonCfg := range c.Chains {
+		if babylonCfg == nil || len(babylonCfg.ChainName) == 0 {
+			return fmt.Errorf("a Babylon chain in the babylon section has no chain-name")
+		}
+		if babylonChainNames[babylonCfg.ChainName] {
+			return fmt.Errorf("Babylon chain %s is specified more than once", babylonCfg.ChainName)
+		}
+		if _, ok := chains[babylonCfg.ChainName]; !ok {
+			return fmt.Errorf("Babylon chain %s is not found in the chains section", babylonCfg.ChainName)
+		}
+		babylonChainNames[babylonCfg.ChainName] = true
+	}
+
+	for _, babylonCfg := range c.Chains {
+		if len(babylonCfg.CZs) == 0 {
+			return fmt.Errorf("Babylon chain %s has no CZ enabled", babylonCfg.ChainName)
+		}
+		if babylonCfg.Batch.MaxMsgs < 0 {
+			return fmt.Errorf("Babylon chain %s has negative max-msgs %d in batch", babylonCfg.ChainName, babylonCfg.Batch.MaxMsgs)
+		}
+		if err := babylonCfg.Keys.validate(); err != nil {
+			return fmt.Errorf("invalid keys of Babylon chain %s: %w", babylonCfg.ChainName, err)
+		}
+		if err := babylonCfg.Grants.validate(chains[babylonCfg.ChainName]); err != nil {
+			return fmt.Errorf("invalid grants of Babylon chain %s: %w", babylonCfg.ChainName, err)
+		}
+		if err := babylonCfg.Balance.validate(); err != nil {
+			return fmt.Errorf("invalid balance config of Babylon chain %s: %w", babylonCfg.ChainName, err)
+		}
+		keys := babylonCfg.KeyNames(chains[babylonCfg.ChainName].ChainProvider.Key())
+		czChainNames := map[string]bool{}
+		for _, czCfg := range babylonCfg.CZs {
+			if czCfg == nil || len(czCfg.ChainName) == 0 {
+				return fmt.Errorf("a CZ of Babylon chain %s has no chain-name", babylonCfg.ChainName)
+			}
+			if czChainNames[czCfg.ChainName] {
+				return fmt.Errorf("CZ %s is enabled more than once on Babylon chain %s", czCfg.ChainName, babylonCfg.ChainName)
+			}
+			czChainNames[czCfg.ChainName] = true
+			if err := czCfg.validate(chains, babylonChainNames, keys); err != nil {
+				return fmt.Errorf("invalid config of CZ %s on Babylon chain %s: %w", czCfg.ChainName, babylonCfg.ChainName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *KeyPoolConfig) validate() error {
+	names := map[string]bool{}
+	for _, name := range c.Names {
+		if len(name) == 0 {
+			return fmt.Errorf("empty key name")
+		}
+		if names[name] {
+			return fmt.Errorf("key %s is specified more than once", name)
+		}
+		names[name] = true
+	}
+	switch c.Strategy {
+	case "", KeyStrategyRoundRobin, KeyStrategyLeastRecentlyUsed, KeyStrategyPinned:
+	default:
+		return fmt.Errorf("unknown key strategy %q, expected %q, %q or %q", c.Strategy, KeyStrategyRoundRobin, KeyStrategyLeastRecentlyUsed, KeyStrategyPinned)
+	}
+	return nil
+}
+
+func (c *GrantsConfig) validate(chain *relayer.Chain) error {
+	cc, ok := chain.ChainProvider.(*cosmos.CosmosProvider)
+	if !ok {
+		return fmt.Errorf("chain is not a Cosmos chain")
+	}
+	for _, granter := range []string{c.FeeGranter, c.AuthzGranter} {
+		if len(granter) == 0 {
+			continue
+		}
+		if _, err := sdk.GetFromBech32(granter, cc.PCfg.AccountPrefix); err != nil {
+			return fmt.Errorf("invalid granter address %s: %w", granter, err)
+		}
+	}
+	return nil
+}
+
+func (c *BalanceConfig) validate() error {
+	if c.WarnThreshold > 0 && c.CriticalThreshold > c.WarnThreshold {
+		return fmt.Errorf("critical threshold %d is above warning threshold %d", c.CriticalThreshold, c.WarnThreshold)
+	}
+	if c.PauseNonCritical && c.CriticalThreshold == 0 {
+		return fmt.Errorf("pause-non-critical requires a critical threshold")
+	}
+	if len(c.Denom) > 0 {
+		if err := sdk.ValidateDenom(c.Denom); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CZConfig) validate(chains relayer.Chains, babylonChainNames map[string]bool, keys []string) error {
+	if _, ok := chains[c.ChainName]; !ok {
+		return fmt.Errorf("chain is not found in the chains section")
+	}
+	if babylonChainNames[c.ChainName] {
+		return fmt.Errorf("chain is a Babylon chain")
+	}
+	if c.Interval < 0 || c.RetryDelay < 0 || c.Timeout < 0 {
+		return fmt.Errorf("negative interval %v, retry delay %v or timeout %v", c.Interval, c.RetryDelay, c.Timeout)
+	}
+	if c.TrustFraction < 0 || c.TrustFraction > 1 {
+		return fmt.Errorf("trust fraction %v is not in (0, 1]", c.TrustFraction)
+	}
+	if c.PollInterval < 0 {
+		return fmt.Errorf("negative poll interval %v", c.PollInterval)
+	}
+	if c.Mode != "" && c.Mode != RelayModeInterval && c.Mode != RelayModeBlocks {
+		return fmt.Errorf("unknown relay mode %q, expected %q or %q", c.Mode, RelayModeInterval, RelayModeBlocks)
+	}
+	if len(c.Key) > 0 && !slices.Contains(keys, c.Key) {
+		return fmt.Errorf("key %s is not in the keys of the Babylon chain %v", c.Key, keys)
+	}
+	if c.Misbehaviour.Interval < 0 {
+		return fmt.Errorf("negative misbehaviour check interval %v", c.Misbehaviour.Interval)
+	}
+	for _, witness := range c.Misbehaviour.Witnesses {
+		if _, err := url.ParseRequestURI(witness); err != nil {
+			return fmt.Errorf("invalid witness %q: %w", witness, err)
+		}
+	}
+	if len(c.Upgrade.PreviousRPCAddr) > 0 {
+		if _, err := url.ParseRequestURI(c.Upgrade.PreviousRPCAddr); err != nil {
+			return fmt.Errorf("invalid RPC address %q of the previous revision: %w", c.Upgrade.PreviousRPCAddr, err)
+		}
+	}
+	if err := c.Client.Validate(); err != nil {
+		return fmt.Errorf("invalid client parameters: %w", err)
+	}
+	return nil
+}
+
+// SetDefaults replaces the zero-valued batch configs, key strategies and
+// balance intervals of all Babylon chains and relay policies and misbehaviour
+// check intervals of all CZs by the given defaults, and
+// validates the resulting relay policies
+func (c *BabylonConfig) SetDefaults(defaultBatch BatchConfig, defaults RelayPolicy) error {
+	for _, babylonCfg := range c.Chains {
+		if babylonCfg.Batch.Window == 0 {
+			babylonCfg.Batch.Window = defaultBatch.Window
+		}
+		if babylonCfg.Batch.MaxMsgs == 0 {
+			babylonCfg.Batch.MaxMsgs = defaultBatch.MaxMsgs
+		}
+		if babylonCfg.Keys.Strategy == "" {
+			babylonCfg.Keys.Strategy = KeyStrategyRoundRobin
+		}
+		if babylonCfg.Balance.Interval == 0 {
+			babylonCfg.Balance.Interval = DefaultBalanceInterval
+		}
+		for _, czCfg := range babylonCfg.CZs {
+			czCfg.SetDefaults(defaults)
+			if czCfg.Misbehaviour.Interval == 0 {
+				czCfg.Misbehaviour.Interval = czCfg.Interval
+			}
+			if err := czCfg.RelayPolicy.Validate(); err != nil {
+				return fmt.Errorf("invalid relay policy of CZ %s on Babylon chain %s: %w", czCfg.ChainName, babylonCfg.ChainName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetDefaults replaces the zero-valued settings of the policy by the given defaults
+func (p *RelayPolicy) SetDefaults(defaults RelayPolicy) {
+	if p.Interval == 0 {
+		p.Interval = defaults.Interval
+	}
+	if p.Retries == 0 {
+		p.Retries = defaults.Retries
+	}
+	if p.RetryDelay == 0 {
+		p.RetryDelay = defaults.RetryDelay
+	}
+	if p.Timeout == 0 {
+		p.Timeout = defaults.Timeout
+	}
+	if p.TrustFraction == 0 {
+		p.TrustFraction = defaults.TrustFraction
+	}
+	if p.Mode == "" {
+		p.Mode = defaults.Mode
+	}
+	if p.BlockDelta == 0 {
+		p.BlockDelta = defaults.BlockDelta
+	}
+	if p.PollInterval == 0 {
+		p.PollInterval = defaults.PollInterval
+	}
+}
+
+// KeyNames returns the names of the keys in the pool of the Babylon chain,
+// where an empty pool only has the given key of the chain
+func (c *BabylonChainConfig) KeyNames(chainKey string) []string {
+	if len(c.Keys.Names) == 0 {
+		return []string{chainKey}
+	}
+	return c.Keys.Names
+}
+
+// Chain returns the config of the given Babylon chain, or nil if the Babylon
+// chain is not in the babylon section
+func (c *BabylonConfig) Chain(babylonChainName string) *BabylonChainConfig {
+	if c == nil {
+		return nil
+	}
+	for _, babylonCfg := range c.Chains {
+		if babylonCfg.ChainName == babylonChainName {
+			return babylonCfg
+		}
+	}
+	return nil
+}
+
+// CZ returns the config of the given CZ on the given Babylon chain, or nil if
+// the CZ is not enabled on the Babylon chain
+func (c *BabylonConfig) CZ(babylonChainName string, czChainName string) *CZConfig {
+	babylonCfg := c.Chain(babylonChainName)
+	if babylonCfg == nil {
+		return nil
+	}
+	for _, czCfg := range babylonCfg.CZs {
+		if czCfg.ChainName == czChainName {
+			return czCfg
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTrustLevel(t *testing.T) {
+	tests := []struct {
+		trustLevel string
+		num        uint64
+		den        uint64
+		valid      bool
+	}{
+		{trustLevel: "1/3", num: 1, den: 3, valid: true},
+		{trustLevel: "2/3", num: 2, den: 3, valid: true},
+		{trustLevel: "1/1", num: 1, den: 1, valid: true},
+		{trustLevel: " 2 / 5 ", num: 2, den: 5, valid: true},
+		{trustLevel: "1/4"},
+		{trustLevel: "4/3"},
+		{trustLevel: "0/0"},
+		{trustLevel: "1/0"},
+		{trustLevel: "1"},
+		{trustLevel: "a/3"},
+		{trustLevel: "1/b"},
+		{trustLevel: "-1/3"},
+		{trustLevel: ""},
+	}
+	for _, tt := range tests {
+		num, den, err := ParseTrustLevel(tt.trustLevel)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("ParseTrustLevel(%q) = %d/%d, expected an error", tt.trustLevel, num, den)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTrustLevel(%q) failed: %v", tt.trustLevel, err)
+			continue
+		}
+		if num != tt.num || den != tt.den {
+			t.Errorf("ParseTrustLevel(%q) = %d/%d, expected %d/%d", tt.trustLevel, num, den, tt.num, tt.den)
+		}
+	}
+}
+
+func TestClientConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   ClientConfig
+		valid bool
+	}{
+		{name: "empty", cfg: ClientConfig{}, valid: true},
+		{name: "trusting period shorter than unbonding period", cfg: ClientConfig{TrustingPeriod: time.Hour, UnbondingPeriod: 2 * time.Hour}, valid: true},
+		{name: "trusting period only", cfg: ClientConfig{TrustingPeriod: time.Hour}, valid: true},
+		{name: "trusting period equal to unbonding period", cfg: ClientConfig{TrustingPeriod: time.Hour, UnbondingPeriod: time.Hour}},
+		{name: "negative trusting period", cfg: ClientConfig{TrustingPeriod: -time.Hour}},
+		{name: "trusting period percentage of 100", cfg: ClientConfig{TrustingPeriodPercentage: 100}},
+		{name: "negative max clock drift", cfg: ClientConfig{MaxClockDrift: -time.Minute}},
+		{name: "invalid trust level", cfg: ClientConfig{TrustLevel: "1/4"}},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -30,9 +30,29 @@ func GetDBPath(homePath string) string {
 	return path.Join(homePath, "db", "client-ids.db")
 }
 
+func GetCostDBPath(homePath string) string {
+	return path.Join(homePath, "db", "costs.db")
+}
+
+// Config is the config of the Babylon relayer, which consists of the config
+// of the official relayer and the Babylon-specific section
+type Config struct {
+	*relayercmd.Config
+
+	// Babylon is the Babylon-specific section of the config file.
+	// It is nil if the config file does not have one.
+	Babylon *BabylonConfig
+}
+
+// babylonConfigWrapper is an intermediary type for parsing the Babylon-specific
+// section, which is ignored by the official relayer
+type babylonConfigWrapper struct {
+	Babylon *BabylonConfig `yaml:"babylon"`
+}
+
 // LoadConfig loads the config file in the given home path to a config struct
 // (adapted from https://github.com/cosmos/relayer/blob/v2.1.2/cmd/config.go#L544)
-func LoadConfig(homePath string, cmd *cobra.Command) (*relayercmd.Config, error) {
+func LoadConfig(homePath string, cmd *cobra.Command) (*Config, error) {
 	// get config path from home path
 	cfgPath := GetCfgPath(homePath)
 	if _, err := os.Stat(cfgPath); err != nil {
@@ -52,6 +72,12 @@ func LoadConfig(homePath string, cmd *cobra.Command) (*relayercmd.Config, error)
 		return nil, fmt.Errorf("failed to unmarshal config file at %s: %v", cfgPath, err)
 	}
 
+	// unmarshall the Babylon-specific section
+	babylonCfgWrapper := &babylonConfigWrapper{}
+	if err := yaml.Unmarshal(file, babylonCfgWrapper); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal babylon section of config file at %s: %v", cfgPath, err)
+	}
+
 	// verify that the channel filter rule is valid for every path in the config
 	for _, p := range cfgWrapper.Paths {
 		if err := p.ValidateChannelFilterRule(); err != nil {
@@ -93,11 +119,21 @@ func LoadConfig(homePath string, cmd *cobra.Command) (*relayercmd.Config, error)
 		chains[chainName] = chain
 	}
 
+	// verify that the Babylon-specific section refers to the chains above
+	if babylonCfgWrapper.Babylon != nil {
+		if err := babylonCfgWrapper.Babylon.Validate(chains); err != nil {
+			return nil, fmt.Errorf("invalid babylon section of config file at %s: %w", cfgPath, err)
+		}
+	}
+
 	// build the config struct
-	config := &relayercmd.Config{
-		Global: cfgWrapper.Global,
-		Chains: chains,
-		Paths:  cfgWrapper.Paths,
+	config := &Config{
+		Config: &relayercmd.Config{
+			Global: cfgWrapper.Global,
+			Chains: chains,
+			Paths:  cfgWrapper.Paths,
+		},
+		Babylon: babylonCfgWrapper.Babylon,
 	}
 
 	return config, nil
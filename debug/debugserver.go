@@ -10,11 +10,28 @@ import (
 	"go.uber.org/zap"
 )
 
+// StatusAPI serves the status of the relayer as JSON, read from the in-memory
+// state of the relayer rather than from Prometheus
+type StatusAPI interface {
+	// ServeStatus serves the status of the relaying loop of every chain
+	ServeStatus(w http.ResponseWriter, req *http.Request)
+	// ServeChain serves the status of the relaying loops of the chain in
+	// the path /chains/{chain}
+	ServeChain(w http.ResponseWriter, req *http.Request)
+	// ServeClients serves the health of the client of every chain
+	ServeClients(w http.ResponseWriter, req *http.Request)
+	// ServeRecords serves the client records stored by the relayer
+	ServeRecords(w http.ResponseWriter, req *http.Request)
+	// ServeCosts serves the cost records stored by the relayer
+	ServeCosts(w http.ResponseWriter, req *http.Request)
+}
+
 // StartDebugServer starts a debug server in a background goroutine,
 // accepting connections on the given listener.
 // Any HTTP logging will be written at info level to the given logger.
 // The server will be forcefully shut down when ctx finishes.
-func StartDebugServer(ctx context.Context, log *zap.Logger, ln net.Listener, metrics *PrometheusMetrics) {
+// If statusAPI is not nil, its endpoints are served as well.
+func StartDebugServer(ctx context.Context, log *zap.Logger, ln net.Listener, metrics *PrometheusMetrics, statusAPI StatusAPI) {
 	// Although we could just import net/http/pprof and rely on the default global server,
 	// we may want many instances of this in test,
 	// and we will probably want more endpoints as time goes on,
@@ -36,6 +53,15 @@ func StartDebugServer(ctx context.Context, log *zap.Logger, ln net.Listener, met
 	promHandler := promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{Registry: metrics.Registry})
 	mux.Handle("/metrics", promHandler)
 
+	// Serve the status API
+	if statusAPI != nil {
+		mux.HandleFunc("/status", statusAPI.ServeStatus)
+		mux.HandleFunc("/chains/", statusAPI.ServeChain)
+		mux.HandleFunc("/clients", statusAPI.ServeClients)
+		mux.HandleFunc("/records", statusAPI.ServeRecords)
+		mux.HandleFunc("/costs", statusAPI.ServeCosts)
+	}
+
 	srv := &http.Server{
 		Handler:  mux,
 		ErrorLog: zap.NewStdLog(log),
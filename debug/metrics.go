@@ -6,16 +6,39 @@ import (
 )
 
 type PrometheusMetrics struct {
-	Registry              *prometheus.Registry
-	RelayedHeadersCounter *prometheus.CounterVec
-	RelayedChainsCounter  *prometheus.CounterVec
-	FailedHeadersCounter  *prometheus.CounterVec
-	FailedChainsCounter   *prometheus.CounterVec
+	Registry               *prometheus.Registry
+	RelayedHeadersCounter  *prometheus.CounterVec
+	RelayedChainsCounter   *prometheus.CounterVec
+	FailedHeadersCounter   *prometheus.CounterVec
+	FailedChainsCounter    *prometheus.CounterVec
+	RestartedChainsCounter *prometheus.CounterVec
+	ChainStateGauge        *prometheus.GaugeVec
+	RelayPolicyGauge       *prometheus.GaugeVec
+	AccountBalanceGauge    *prometheus.GaugeVec
+	AccountRunwayGauge     *prometheus.GaugeVec
+	BalanceAlertGauge      *prometheus.GaugeVec
+	PausedChainsGauge      *prometheus.GaugeVec
+	GasUsedCounter         *prometheus.CounterVec
+	GasWantedCounter       *prometheus.CounterVec
+	FeesPaidCounter        *prometheus.CounterVec
+	GasUsedHistogram       *prometheus.HistogramVec
+	FeesPaidHistogram      *prometheus.HistogramVec
+	ClientStatusGauge      *prometheus.GaugeVec
+	MisbehavioursCounter   *prometheus.CounterVec
 }
 
 func NewPrometheusMetrics() *PrometheusMetrics {
 	headerLabels := []string{"src_chain", "dst_chain"}
+	failedHeaderLabels := []string{"src_chain", "dst_chain", "error_class"}
 	chainLabels := []string{"src_chain", "dst_chain"}
+	chainStateLabels := []string{"src_chain", "dst_chain", "state"}
+	relayPolicyLabels := []string{"src_chain", "dst_chain", "setting"}
+	accountBalanceLabels := []string{"chain", "account", "denom"}
+	accountRunwayLabels := []string{"chain", "account", "unit"}
+	accountLabels := []string{"chain", "account"}
+	feeLabels := []string{"src_chain", "dst_chain", "denom"}
+	clientStatusLabels := []string{"src_chain", "dst_chain", "status"}
+	misbehaviourLabels := []string{"src_chain", "dst_chain", "action"}
 	registry := prometheus.NewRegistry()
 	registerer := promauto.With(registry)
 	metrics := &PrometheusMetrics{
@@ -31,11 +54,69 @@ func NewPrometheusMetrics() *PrometheusMetrics {
 		FailedHeadersCounter: registerer.NewCounterVec(prometheus.CounterOpts{
 			Name: "cosmos_relayer_failed_headers",
 			Help: "The total number of headers that are failed to be relayed",
-		}, headerLabels),
+		}, failedHeaderLabels),
 		FailedChainsCounter: registerer.NewCounterVec(prometheus.CounterOpts{
 			Name: "cosmos_relayer_failed_chains",
 			Help: "The total number of chains that are failed to be relayed",
 		}, chainLabels),
+		RestartedChainsCounter: registerer.NewCounterVec(prometheus.CounterOpts{
+			Name: "cosmos_relayer_restarted_chains",
+			Help: "The total number of times that the relaying of a chain is restarted after failures",
+		}, chainLabels),
+		ChainStateGauge: registerer.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cosmos_relayer_chain_state",
+			Help: "The state of the relaying loop of a chain (1 for the current state, 0 otherwise)",
+		}, chainStateLabels),
+		RelayPolicyGauge: registerer.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cosmos_relayer_relay_policy",
+			Help: "The effective relay policy of a chain, i.e., interval, retries, retry delay, timeout and trust fraction",
+		}, relayPolicyLabels),
+		AccountBalanceGauge: registerer.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cosmos_relayer_account_balance",
+			Help: "The balance of an account that pays the fees of client updates",
+		}, accountBalanceLabels),
+		AccountRunwayGauge: registerer.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cosmos_relayer_account_runway",
+			Help: "The estimated remaining number of client updates or seconds that the balance of an account can pay for",
+		}, accountRunwayLabels),
+		BalanceAlertGauge: registerer.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cosmos_relayer_balance_alert",
+			Help: "The alert level of the balance of an account (0 for ok, 1 for below the warning threshold, 2 for below the critical threshold)",
+		}, accountLabels),
+		PausedChainsGauge: registerer.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cosmos_relayer_paused_chains",
+			Help: "Whether the client updates of a chain are paused to save fees (1 for paused, 0 otherwise)",
+		}, chainLabels),
+		GasUsedCounter: registerer.NewCounterVec(prometheus.CounterOpts{
+			Name: "cosmos_relayer_gas_used",
+			Help: "The total gas used by the txs of a chain, where the gas of a tx is split among the chains of its msgs",
+		}, chainLabels),
+		GasWantedCounter: registerer.NewCounterVec(prometheus.CounterOpts{
+			Name: "cosmos_relayer_gas_wanted",
+			Help: "The total gas limit of the txs of a chain, where the gas of a tx is split among the chains of its msgs",
+		}, chainLabels),
+		FeesPaidCounter: registerer.NewCounterVec(prometheus.CounterOpts{
+			Name: "cosmos_relayer_fees_paid",
+			Help: "The total fees paid for the txs of a chain, where the fees of a tx are split among the chains of its msgs",
+		}, feeLabels),
+		GasUsedHistogram: registerer.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cosmos_relayer_tx_gas_used",
+			Help:    "The gas used by a chain in a tx",
+			Buckets: prometheus.ExponentialBuckets(25000, 2, 10),
+		}, chainLabels),
+		FeesPaidHistogram: registerer.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cosmos_relayer_tx_fees_paid",
+			Help:    "The fees paid by a chain in a tx",
+			Buckets: prometheus.ExponentialBuckets(100, 4, 10),
+		}, feeLabels),
+		ClientStatusGauge: registerer.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cosmos_relayer_client_status",
+			Help: "The status of the light client of a chain (1 for the current status, 0 otherwise), where Expired and Frozen clients require a recovery action",
+		}, clientStatusLabels),
+		MisbehavioursCounter: registerer.NewCounterVec(prometheus.CounterOpts{
+			Name: "cosmos_relayer_misbehaviours",
+			Help: "The total number of conflicting headers of a chain, by the action taken, i.e., halted, submitted or submission_failed",
+		}, misbehaviourLabels),
 	}
 	return metrics
 }